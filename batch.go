@@ -0,0 +1,195 @@
+package choco
+
+import (
+	"context"
+	"io"
+	"iter"
+	"net/http"
+	"sync"
+)
+
+// BatchOptions configures [Pipeline.ExecuteAll].
+type BatchOptions struct {
+	// Concurrency is the maximum number of requests in flight.
+	// Zero or a negative value means one.
+	Concurrency int
+
+	// StopOnError cancels the batch on the first failed request: no new
+	// request is started, requests in flight are cancelled, and iteration
+	// ends after the failing result. Responses already yielded are left
+	// untouched.
+	StopOnError bool
+
+	// Ordered yields results in input order instead of completion order.
+	// Results waiting for an earlier one count towards Concurrency.
+	Ordered bool
+}
+
+// BatchResult is the outcome of one request of a batch.
+type BatchResult struct {
+	Response *http.Response
+	Err      error
+}
+
+// ExecuteAll executes every request of reqs through the pipeline, with up to
+// opts.Concurrency requests in flight, and yields the index of each request in
+// reqs along with its result.
+//
+// Results are yielded in completion order, or in input order when opts.Ordered
+// is set. Callers must close the bodies of the responses they receive.
+//
+// When ctx is done, or when the consumer stops iterating early, pending and
+// in-flight requests are cancelled and the responses that were never yielded
+// are closed. A received response no longer depends on the batch: its context
+// is released when its body is closed.
+//
+// Example:
+//
+//	for i, res := range pipeline.ExecuteAll(ctx, slices.Values(reqs), BatchOptions{Concurrency: 8}) {
+//	    if res.Err != nil {
+//	        log.Printf("request %d failed: %v", i, res.Err)
+//	        continue
+//	    }
+//	    res.Response.Body.Close()
+//	}
+func (p Pipeline) ExecuteAll(ctx context.Context, reqs iter.Seq[*Request], opts BatchOptions) iter.Seq2[int, BatchResult] {
+	return func(yield func(int, BatchResult) bool) {
+		concurrency := max(opts.Concurrency, 1)
+		batchCtx, cancel := context.WithCancelCause(ctx)
+		defer cancel(context.Canceled)
+
+		type indexed struct {
+			index int
+			BatchResult
+		}
+		results := make(chan indexed)
+		var wg sync.WaitGroup
+
+		// A slot is held until the result is received, or in ordered mode
+		// until it is yielded, so that parked results count as in flight.
+		sem := make(chan struct{}, concurrency)
+
+		// Producer: pull requests and start them while slots are available.
+		go func() {
+			defer func() {
+				wg.Wait()
+				close(results)
+			}()
+
+			i := 0
+			for req := range reqs {
+				select {
+				case sem <- struct{}{}:
+				case <-batchCtx.Done():
+					return
+				}
+				if batchCtx.Err() != nil {
+					return
+				}
+
+				wg.Add(1)
+				go func(i int, req *Request) {
+					defer wg.Done()
+					if !opts.Ordered {
+						defer func() { <-sem }()
+					}
+
+					var res BatchResult
+					if req == nil {
+						res.Err = NewError("request is nil ")
+					} else {
+						res = p.executeInBatch(batchCtx, req)
+					}
+					if res.Err != nil && opts.StopOnError {
+						cancel(res.Err)
+					}
+					results <- indexed{i, res}
+				}(i, req)
+				i++
+			}
+		}()
+
+		// Once iteration ends early, cancel the batch and close what is left.
+		stop := func() {
+			cancel(context.Canceled)
+			go func() {
+				for r := range results {
+					closeResponse(r.Response)
+				}
+			}()
+		}
+
+		if !opts.Ordered {
+			for r := range results {
+				if !yield(r.index, r.BatchResult) {
+					stop()
+					return
+				}
+				if r.Err != nil && opts.StopOnError {
+					stop()
+					return
+				}
+			}
+			return
+		}
+
+		pending := map[int]BatchResult{}
+		next := 0
+		for r := range results {
+			pending[r.index] = r.BatchResult
+			for {
+				res, ok := pending[next]
+				if !ok {
+					break
+				}
+				delete(pending, next)
+				if !yield(next, res) || (res.Err != nil && opts.StopOnError) {
+					for _, left := range pending {
+						closeResponse(left.Response)
+					}
+					stop()
+					return
+				}
+				<-sem
+				next++
+			}
+		}
+	}
+}
+
+// executeInBatch executes req with a context also cancelled, with the same
+// cause, when batchCtx is done while the request is in flight. Once a response
+// is received it is detached from batchCtx, and its context is released when
+// its body is closed.
+func (p Pipeline) executeInBatch(batchCtx context.Context, req *Request) BatchResult {
+	ctx, cancel := context.WithCancelCause(req.Raw().Context())
+	stop := context.AfterFunc(batchCtx, func() {
+		cancel(context.Cause(batchCtx))
+	})
+	resp, err := p.Execute(req.WithContext(ctx))
+	stop()
+	if resp == nil || resp.Body == nil {
+		cancel(context.Canceled)
+	} else {
+		resp.Body = &batchBody{ReadCloser: resp.Body, cancel: cancel}
+	}
+	return BatchResult{Response: resp, Err: err}
+}
+
+// batchBody releases the context of a batch request when the body is closed.
+type batchBody struct {
+	io.ReadCloser
+	cancel context.CancelCauseFunc
+}
+
+func (b *batchBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel(context.Canceled)
+	return err
+}
+
+func closeResponse(resp *http.Response) {
+	if resp != nil && resp.Body != nil {
+		resp.Body.Close()
+	}
+}
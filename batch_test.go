@@ -0,0 +1,315 @@
+package choco
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"nyxze/choco-go/chocotest"
+)
+
+// batchTransport answers /n with body n after n milliseconds, and fails /fail.
+type batchTransport struct {
+	inFlight, maxInFlight atomic.Int32
+	opened, closed        atomic.Int32
+}
+
+func (b *batchTransport) Send(req *http.Request) (*http.Response, error) {
+	n := b.inFlight.Add(1)
+	defer b.inFlight.Add(-1)
+	for {
+		m := b.maxInFlight.Load()
+		if n <= m || b.maxInFlight.CompareAndSwap(m, n) {
+			break
+		}
+	}
+
+	name := req.URL.Path[1:]
+	if name == "fail" {
+		return nil, errors.New("boom")
+	}
+	ms, _ := strconv.Atoi(name)
+	select {
+	case <-time.After(time.Duration(ms) * time.Millisecond):
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+	b.opened.Add(1)
+	body := &trackedBody{ReadCloser: io.NopCloser(strings.NewReader(name)), closed: &b.closed}
+	return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: body}, nil
+}
+
+type trackedBody struct {
+	io.ReadCloser
+	closed *atomic.Int32
+}
+
+func (t *trackedBody) Close() error {
+	t.closed.Add(1)
+	return t.ReadCloser.Close()
+}
+
+func batchRequests(t *testing.T, names ...string) iter.Seq[*Request] {
+	t.Helper()
+	reqs := make([]*Request, len(names))
+	for i, name := range names {
+		req, err := NewRequest(context.Background(), "GET", "http://batch.example.com/"+name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reqs[i] = req
+	}
+	return slices.Values(reqs)
+}
+
+func TestExecuteAllOrder(t *testing.T) {
+	names := []string{"30", "1", "15", "5"}
+	for _, ordered := range []bool{false, true} {
+		t.Run(fmt.Sprintf("ordered=%v", ordered), func(t *testing.T) {
+			tr := &batchTransport{}
+			p, err := NewPipeline(WithCustomTransport(tr))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []int
+			for i, res := range p.ExecuteAll(context.Background(), batchRequests(t, names...), BatchOptions{Concurrency: 4, Ordered: ordered}) {
+				if res.Err != nil {
+					t.Fatal(res.Err)
+				}
+				b, _ := io.ReadAll(res.Response.Body)
+				res.Response.Body.Close()
+				if string(b) != names[i] {
+					t.Errorf("result %d has body %q, want %q", i, b, names[i])
+				}
+				got = append(got, i)
+			}
+			want := []int{1, 3, 2, 0}
+			if ordered {
+				want = []int{0, 1, 2, 3}
+			}
+			if !slices.Equal(got, want) {
+				t.Errorf("indexes = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestExecuteAllConcurrencyLimit(t *testing.T) {
+	tr := &batchTransport{}
+	p, err := NewPipeline(WithCustomTransport(tr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := slices.Repeat([]string{"5"}, 12)
+	n := 0
+	for _, res := range p.ExecuteAll(context.Background(), batchRequests(t, names...), BatchOptions{Concurrency: 3}) {
+		if res.Err != nil {
+			t.Fatal(res.Err)
+		}
+		res.Response.Body.Close()
+		n++
+	}
+	if n != 12 {
+		t.Errorf("got %d results, want 12", n)
+	}
+	if m := tr.maxInFlight.Load(); m > 3 {
+		t.Errorf("max in flight = %d, want <= 3", m)
+	}
+}
+
+func TestExecuteAllOrderedConcurrencyLimit(t *testing.T) {
+	tr := &batchTransport{}
+	p, err := NewPipeline(WithCustomTransport(tr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// While the first request runs, later results are parked, not started.
+	names := append([]string{"100"}, slices.Repeat([]string{"1"}, 20)...)
+	n := 0
+	for i, res := range p.ExecuteAll(context.Background(), batchRequests(t, names...), BatchOptions{Concurrency: 4, Ordered: true}) {
+		if res.Err != nil {
+			t.Fatal(res.Err)
+		}
+		if i == 0 {
+			if open := tr.opened.Load() - tr.closed.Load(); open > 4 {
+				t.Errorf("%d bodies open while the first request ran, want <= 4", open)
+			}
+		}
+		res.Response.Body.Close()
+		n++
+	}
+	if n != len(names) {
+		t.Errorf("got %d results, want %d", n, len(names))
+	}
+}
+
+func TestExecuteAllEarlyStop(t *testing.T) {
+	tr := &batchTransport{}
+	p, err := NewPipeline(WithCustomTransport(tr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, res := range p.ExecuteAll(context.Background(), batchRequests(t, "1", "2", "3", "500", "500"), BatchOptions{Concurrency: 5}) {
+		if res.Err != nil {
+			t.Fatal(res.Err)
+		}
+		res.Response.Body.Close()
+		// Let the other fast requests complete before stopping.
+		time.Sleep(50 * time.Millisecond)
+		break
+	}
+	// The two other fast responses are closed for us, the slow ones cancelled.
+	deadline := time.Now().Add(time.Second)
+	for tr.closed.Load() < 3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if c := tr.closed.Load(); c != 3 {
+		t.Errorf("closed %d bodies, want 3", c)
+	}
+}
+
+func TestExecuteAllStopOnError(t *testing.T) {
+	tr := chocotest.NewTransport()
+	tr.On("GET", "/fail").RespondError(errors.New("boom"))
+	tr.On("GET", "/slow").Respond(chocotest.Status(http.StatusOK).WithDelay(time.Second))
+	p, err := NewPipeline(WithCustomTransport(tr))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	var errs int
+	for _, res := range p.ExecuteAll(context.Background(), batchRequests(t, "slow", "fail", "slow"), BatchOptions{Concurrency: 3, StopOnError: true}) {
+		if res.Err != nil {
+			errs++
+		}
+	}
+	if errs != 1 {
+		t.Errorf("got %d errors, want iteration to stop after the first", errs)
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Error("slow requests were not cancelled")
+	}
+}
+
+func TestExecuteAllStopOnErrorKeepsYieldedResponses(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "part1,")
+		w.(http.Flusher).Flush()
+		<-release
+		io.WriteString(w, "part2")
+	}))
+	defer srv.Close()
+
+	// /fail only fails once /ok has been yielded.
+	yielded := make(chan struct{})
+	failing := PipelineStepFunc(func(req *Request, next RequestHandlerFunc) (*http.Response, error) {
+		if req.Raw().URL.Path != "/fail" {
+			return next(req)
+		}
+		<-yielded
+		return nil, errors.New("boom")
+	})
+	p, err := NewPipeline(WithSteps(failing))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.CloseIdleConnections()
+
+	var reqs []*Request
+	for _, path := range []string{"/ok", "/fail"} {
+		req, err := NewRequest(context.Background(), "GET", srv.URL+path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reqs = append(reqs, req)
+	}
+	var ok *http.Response
+	for i, res := range p.ExecuteAll(context.Background(), slices.Values(reqs), BatchOptions{Concurrency: 2, StopOnError: true}) {
+		if i == 0 {
+			ok = res.Response
+			close(yielded)
+		}
+	}
+	if ok == nil {
+		t.Fatal("/ok was not yielded")
+	}
+	defer ok.Body.Close()
+
+	close(release)
+	b, err := io.ReadAll(ok.Body)
+	if err != nil || string(b) != "part1,part2" {
+		t.Errorf("yielded body = %q, err = %v", b, err)
+	}
+}
+
+// afterFuncContext counts the callbacks registered on it, by context.AfterFunc
+// and by the contexts derived from it, that are still waiting.
+type afterFuncContext struct {
+	context.Context
+	done chan struct{}
+
+	mu      sync.Mutex
+	waiting int
+}
+
+func (c *afterFuncContext) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *afterFuncContext) AfterFunc(func()) func() bool {
+	c.mu.Lock()
+	c.waiting++
+	c.mu.Unlock()
+	var once sync.Once
+	return func() bool {
+		stopped := false
+		once.Do(func() {
+			c.mu.Lock()
+			c.waiting--
+			c.mu.Unlock()
+			stopped = true
+		})
+		return stopped
+	}
+}
+
+func TestExecuteAllReleasesContexts(t *testing.T) {
+	ctx := &afterFuncContext{Context: context.Background(), done: make(chan struct{})}
+	p, err := NewPipeline(WithCustomTransport(&batchTransport{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reqs []*Request
+	for _, name := range []string{"1", "2", "3"} {
+		req, err := NewRequest(ctx, "GET", "http://batch.example.com/"+name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reqs = append(reqs, req)
+	}
+	for _, res := range p.ExecuteAll(ctx, slices.Values(reqs), BatchOptions{Concurrency: 2}) {
+		if res.Err != nil {
+			t.Fatal(res.Err)
+		}
+		res.Response.Body.Close()
+	}
+
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	if ctx.waiting != 0 {
+		t.Errorf("%d callbacks still registered on the parent context after the batch", ctx.waiting)
+	}
+}
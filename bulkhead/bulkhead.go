@@ -0,0 +1,272 @@
+// Package bulkhead provides a [choco.PipelineStep] capping the number of
+// concurrent requests per key, so that one slow upstream cannot take all the
+// goroutines and connections of a client.
+//
+//	step := bulkhead.NewStep(
+//	    bulkhead.WithMaxConcurrent(8),
+//	    bulkhead.WithMaxQueue(32),
+//	    bulkhead.WithQueueTimeout(time.Second),
+//	)
+//	pipeline, err := choco.NewPipeline(choco.WithSteps(step))
+//
+// Requests are keyed by host by default. A request holds its slot until its
+// response body is closed or fully read, or until the rest of the pipeline
+// returns an error.
+package bulkhead
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"nyxze/choco-go"
+)
+
+// DefaultMaxConcurrent is the number of concurrent requests per key allowed
+// when [WithMaxConcurrent] is not used.
+const DefaultMaxConcurrent = 10
+
+// ErrRejected is matched by every [*RejectedError] with [errors.Is].
+var ErrRejected = errors.New("bulkhead: request rejected")
+
+// Reason tells why a request was rejected.
+type Reason string
+
+const (
+	// ReasonQueueFull means all slots were taken and the queue was full.
+	ReasonQueueFull Reason = "queue full"
+	// ReasonQueueTimeout means the request waited in the queue for longer
+	// than the queue timeout.
+	ReasonQueueTimeout Reason = "queue timeout"
+)
+
+// RejectedError is returned when the bulkhead refuses a request.
+type RejectedError struct {
+	// Key is the bulkhead compartment of the request.
+	Key    string
+	Reason Reason
+}
+
+func (e *RejectedError) Error() string {
+	return fmt.Sprintf("bulkhead: request to %q rejected: %s", e.Key, e.Reason)
+}
+
+// Is reports whether target is [ErrRejected].
+func (e *RejectedError) Is(target error) bool {
+	return target == ErrRejected
+}
+
+// Stats are the counters of one bulkhead compartment.
+type Stats struct {
+	// Active is the number of requests holding a slot.
+	Active int
+	// Queued is the number of requests waiting for a slot.
+	Queued int
+}
+
+// Option configures a [Step].
+type Option func(*Step)
+
+// WithMaxConcurrent allows n concurrent requests per key. Values below one
+// are ignored.
+func WithMaxConcurrent(n int) Option {
+	return func(s *Step) {
+		if n > 0 {
+			s.maxConcurrent = n
+		}
+	}
+}
+
+// WithMaxQueue lets up to n requests per key wait for a slot. Without a
+// queue, which is the default, requests are rejected as soon as all slots
+// are taken.
+func WithMaxQueue(n int) Option {
+	return func(s *Step) {
+		s.maxQueue = max(n, 0)
+	}
+}
+
+// WithQueueTimeout rejects queued requests that did not get a slot within d.
+// Zero, the default, lets them wait until their context is done.
+func WithQueueTimeout(d time.Duration) Option {
+	return func(s *Step) {
+		s.queueTimeout = d
+	}
+}
+
+// WithKeyFunc sets the function partitioning requests into compartments.
+// The default keys requests by host.
+func WithKeyFunc(fn func(*choco.Request) string) Option {
+	return func(s *Step) {
+		s.key = fn
+	}
+}
+
+// Step is a [choco.PipelineStep] limiting concurrent requests per key.
+// It is safe for concurrent use.
+type Step struct {
+	maxConcurrent int
+	maxQueue      int
+	queueTimeout  time.Duration
+	key           func(*choco.Request) string
+
+	mu           sync.Mutex
+	compartments map[string]*compartment
+}
+
+// compartment tracks the slots of one key. Its fields are guarded by Step.mu.
+type compartment struct {
+	active  int
+	waiters []chan struct{}
+}
+
+// NewStep creates a bulkhead [Step].
+func NewStep(opts ...Option) *Step {
+	s := &Step{
+		maxConcurrent: DefaultMaxConcurrent,
+		key:           hostKey,
+		compartments:  map[string]*compartment{},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+func hostKey(req *choco.Request) string {
+	return req.Raw().URL.Host
+}
+
+// Name implements [choco.NamedStep].
+func (s *Step) Name() string {
+	return "bulkhead"
+}
+
+// Do implements [choco.PipelineStep].
+func (s *Step) Do(req *choco.Request, next choco.RequestHandlerFunc) (*http.Response, error) {
+	key := s.key(req)
+	if err := s.acquire(req.Raw().Context(), key); err != nil {
+		return nil, err
+	}
+	release := sync.OnceFunc(func() { s.release(key) })
+
+	resp, err := next(req)
+	if err != nil || resp == nil || resp.Body == nil {
+		release()
+		return resp, err
+	}
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+// Stats returns the counters of the compartment for key.
+func (s *Step) Stats(key string) Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.compartments[key]
+	if !ok {
+		return Stats{}
+	}
+	return Stats{Active: c.active, Queued: len(c.waiters)}
+}
+
+// Snapshot returns the counters of every compartment in use.
+func (s *Step) Snapshot() map[string]Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := make(map[string]Stats, len(s.compartments))
+	for key, c := range s.compartments {
+		stats[key] = Stats{Active: c.active, Queued: len(c.waiters)}
+	}
+	return stats
+}
+
+// acquire takes a slot for key, waiting in the queue if allowed.
+func (s *Step) acquire(ctx context.Context, key string) error {
+	s.mu.Lock()
+	c, ok := s.compartments[key]
+	if !ok {
+		c = &compartment{}
+		s.compartments[key] = c
+	}
+	if c.active < s.maxConcurrent {
+		c.active++
+		s.mu.Unlock()
+		return nil
+	}
+	if len(c.waiters) >= s.maxQueue {
+		s.mu.Unlock()
+		return &RejectedError{Key: key, Reason: ReasonQueueFull}
+	}
+	ready := make(chan struct{})
+	c.waiters = append(c.waiters, ready)
+	s.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if s.queueTimeout > 0 {
+		timer := time.NewTimer(s.queueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	var err error
+	select {
+	case <-ready:
+		return nil
+	case <-timeout:
+		err = &RejectedError{Key: key, Reason: ReasonQueueTimeout}
+	case <-ctx.Done():
+		err = context.Cause(ctx)
+	}
+
+	s.mu.Lock()
+	if i := slices.Index(c.waiters, ready); i >= 0 {
+		c.waiters = slices.Delete(c.waiters, i, i+1)
+		s.mu.Unlock()
+		return err
+	}
+	s.mu.Unlock()
+	// The slot was handed over while giving up: pass it on.
+	s.release(key)
+	return err
+}
+
+// release frees a slot of key, handing it to the first queued request.
+func (s *Step) release(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.compartments[key]
+	if len(c.waiters) > 0 {
+		close(c.waiters[0])
+		c.waiters = c.waiters[1:]
+		return
+	}
+	c.active--
+	if c.active == 0 {
+		delete(s.compartments, key)
+	}
+}
+
+// releasingBody frees the slot of its request once read to the end or closed.
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releasingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil {
+		b.release()
+	}
+	return n, err
+}
+
+func (b *releasingBody) Close() error {
+	defer b.release()
+	return b.ReadCloser.Close()
+}
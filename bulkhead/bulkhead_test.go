@@ -0,0 +1,170 @@
+package bulkhead_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"nyxze/choco-go"
+	"nyxze/choco-go/bulkhead"
+)
+
+// gateTransport blocks every request until release is closed.
+type gateTransport struct {
+	started chan string
+	release chan struct{}
+}
+
+func newGateTransport() *gateTransport {
+	return &gateTransport{started: make(chan string, 16), release: make(chan struct{})}
+}
+
+func (g *gateTransport) Send(req *http.Request) (*http.Response, error) {
+	g.started <- req.URL.Host
+	select {
+	case <-g.release:
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+	return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader("ok"))}, nil
+}
+
+func newRequest(t *testing.T, url string) *choco.Request {
+	t.Helper()
+	req, err := choco.NewRequest(context.Background(), "GET", url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
+
+func newPipeline(t *testing.T, tr choco.Transport, step *bulkhead.Step) choco.Pipeline {
+	t.Helper()
+	p, err := choco.NewPipeline(choco.WithCustomTransport(tr), choco.WithSteps(step))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestRejectWhenFull(t *testing.T) {
+	tr := newGateTransport()
+	step := bulkhead.NewStep(bulkhead.WithMaxConcurrent(1))
+	p := newPipeline(t, tr, step)
+
+	done := make(chan error)
+	a := newRequest(t, "http://a.example.com/")
+	go func() {
+		resp, err := p.Execute(a)
+		if err == nil {
+			resp.Body.Close()
+		}
+		done <- err
+	}()
+	<-tr.started
+
+	_, err := p.Execute(newRequest(t, "http://a.example.com/"))
+	var rejected *bulkhead.RejectedError
+	if !errors.As(err, &rejected) || rejected.Reason != bulkhead.ReasonQueueFull || !errors.Is(err, bulkhead.ErrRejected) {
+		t.Errorf("err = %v, want queue full rejection", err)
+	}
+
+	// Other hosts have their own compartment.
+	b := newRequest(t, "http://b.example.com/")
+	go func() {
+		resp, err := p.Execute(b)
+		if err == nil {
+			resp.Body.Close()
+		}
+		done <- err
+	}()
+	<-tr.started
+	if got := step.Snapshot(); got["a.example.com"].Active != 1 || got["b.example.com"].Active != 1 {
+		t.Errorf("Snapshot() = %v", got)
+	}
+
+	close(tr.release)
+	for range 2 {
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := step.Snapshot(); len(got) != 0 {
+		t.Errorf("Snapshot() after completion = %v, want empty", got)
+	}
+}
+
+func TestQueue(t *testing.T) {
+	tr := newGateTransport()
+	step := bulkhead.NewStep(bulkhead.WithMaxConcurrent(1), bulkhead.WithMaxQueue(1))
+	p := newPipeline(t, tr, step)
+
+	close(tr.release)
+	first, err := p.Execute(newRequest(t, "http://a.example.com/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The slot is held until the body is closed.
+	queued := make(chan error)
+	second := newRequest(t, "http://a.example.com/")
+	go func() {
+		resp, err := p.Execute(second)
+		if err == nil {
+			_, err = io.ReadAll(resp.Body)
+		}
+		queued <- err
+	}()
+	for step.Stats("a.example.com").Queued != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := p.Execute(newRequest(t, "http://a.example.com/")); !errors.Is(err, bulkhead.ErrRejected) {
+		t.Errorf("err = %v, want rejection with a full queue", err)
+	}
+
+	first.Body.Close()
+	if err := <-queued; err != nil {
+		t.Fatal(err)
+	}
+	// Reading the body to the end released the slot.
+	if got := step.Stats("a.example.com"); got != (bulkhead.Stats{}) {
+		t.Errorf("Stats() = %+v, want zero", got)
+	}
+}
+
+func TestQueueTimeout(t *testing.T) {
+	tr := newGateTransport()
+	defer close(tr.release)
+	step := bulkhead.NewStep(
+		bulkhead.WithMaxConcurrent(1),
+		bulkhead.WithMaxQueue(1),
+		bulkhead.WithQueueTimeout(20*time.Millisecond),
+	)
+	p := newPipeline(t, tr, step)
+
+	go p.Execute(newRequest(t, "http://a.example.com/"))
+	<-tr.started
+
+	_, err := p.Execute(newRequest(t, "http://a.example.com/"))
+	var rejected *bulkhead.RejectedError
+	if !errors.As(err, &rejected) || rejected.Reason != bulkhead.ReasonQueueTimeout {
+		t.Errorf("err = %v, want queue timeout rejection", err)
+	}
+	if got := step.Stats("a.example.com"); got.Queued != 0 || got.Active != 1 {
+		t.Errorf("Stats() = %+v, want one active request", got)
+	}
+
+	// A cancelled context leaves the queue with its own error.
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := choco.NewRequest(ctx, "GET", "http://a.example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if _, err := p.Execute(req); !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+}
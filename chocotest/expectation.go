@@ -0,0 +1,176 @@
+package chocotest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// Expectation matches requests received by a [Transport] and answers them.
+// Configure it with the With* methods and one of the Respond methods.
+type Expectation struct {
+	method   string
+	path     string
+	query    map[string][]string
+	header   http.Header
+	jsonBody any
+	hasJSON  bool
+
+	responses []Response
+	times     int
+	calls     int
+}
+
+// WithQuery requires the query parameter key to have exactly the given values.
+func (e *Expectation) WithQuery(key string, values ...string) *Expectation {
+	e.query[key] = values
+	return e
+}
+
+// WithHeader requires the request header key to contain value.
+func (e *Expectation) WithHeader(key, value string) *Expectation {
+	e.header.Add(key, value)
+	return e
+}
+
+// WithJSONBody requires the request body to be JSON equal to v once both
+// sides are decoded, so key order and whitespace don't matter.
+func (e *Expectation) WithJSONBody(v any) *Expectation {
+	e.jsonBody = normalizeJSON(v)
+	e.hasJSON = true
+	return e
+}
+
+// Respond scripts the responses returned by this expectation. Successive
+// matching requests get the responses in order; once the sequence is used up,
+// the last response is repeated, unless a call count was set with [Expectation.Times].
+func (e *Expectation) Respond(responses ...Response) *Expectation {
+	e.responses = append(e.responses, responses...)
+	return e
+}
+
+// RespondError is a shortcut for Respond(Error(err)).
+func (e *Expectation) RespondError(err error) *Expectation {
+	return e.Respond(Error(err))
+}
+
+// Times limits the expectation to n matching requests. Once used n times it
+// no longer matches, and [Transport.AssertExpectations] requires exactly n calls.
+func (e *Expectation) Times(n int) *Expectation {
+	e.times = n
+	return e
+}
+
+// Once is a shortcut for Times(1).
+func (e *Expectation) Once() *Expectation {
+	return e.Times(1)
+}
+
+// String describes the expectation, e.g. "GET /users/42".
+func (e *Expectation) String() string {
+	method := e.method
+	if method == "" {
+		method = "*"
+	}
+	path := e.path
+	if path == "" {
+		path = "*"
+	}
+	return method + " " + path
+}
+
+func (e *Expectation) exhausted() bool {
+	return e.times >= 0 && e.calls >= e.times
+}
+
+// next returns the response for the current call. Callers must hold the transport lock.
+func (e *Expectation) next() Response {
+	i := e.calls
+	e.calls++
+	if len(e.responses) == 0 {
+		return Response{}
+	}
+	return e.responses[min(i, len(e.responses)-1)]
+}
+
+func (e *Expectation) matches(req *http.Request, body []byte) bool {
+	if e.method != "" && e.method != "*" && e.method != req.Method {
+		return false
+	}
+	if !matchPath(e.path, req.URL.Path) {
+		return false
+	}
+
+	q := req.URL.Query()
+	for key, want := range e.query {
+		if !reflect.DeepEqual(q[key], want) {
+			return false
+		}
+	}
+
+	for key, want := range e.header {
+		got := req.Header.Values(key)
+		for _, v := range want {
+			if !containsString(got, v) {
+				return false
+			}
+		}
+	}
+
+	if e.hasJSON {
+		var got any
+		if err := json.Unmarshal(body, &got); err != nil {
+			return false
+		}
+		if !reflect.DeepEqual(got, e.jsonBody) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchPath(pattern, path string) bool {
+	if pattern == "" {
+		return true
+	}
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return strings.HasPrefix(path, prefix)
+	}
+	return pattern == path
+}
+
+func containsString(values []string, v string) bool {
+	for _, s := range values {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeJSON round-trips v through encoding/json so that it compares
+// equal to a decoded request body.
+func normalizeJSON(v any) any {
+	var b []byte
+	switch x := v.(type) {
+	case []byte:
+		b = x
+	case string:
+		b = []byte(x)
+	case json.RawMessage:
+		b = x
+	default:
+		var err error
+		b, err = json.Marshal(v)
+		if err != nil {
+			panic(fmt.Sprintf("chocotest: cannot marshal expected JSON body: %v", err))
+		}
+	}
+	var out any
+	if err := json.Unmarshal(b, &out); err != nil {
+		panic(fmt.Sprintf("chocotest: invalid expected JSON body: %v", err))
+	}
+	return out
+}
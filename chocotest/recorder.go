@@ -0,0 +1,451 @@
+package chocotest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Mode selects how a [Recorder] uses its cassette.
+type Mode int
+
+const (
+	// ModeReplay serves every request from the cassette and never reaches the network.
+	ModeReplay Mode = iota
+	// ModeRecord sends every request to the inner transport and overwrites the cassette.
+	ModeRecord
+	// ModeReplayOrRecord replays matching interactions and records the others,
+	// appending them to the cassette.
+	ModeReplayOrRecord
+)
+
+// String returns the name of the mode.
+func (m Mode) String() string {
+	switch m {
+	case ModeReplay:
+		return "replay"
+	case ModeRecord:
+		return "record"
+	case ModeReplayOrRecord:
+		return "replay-or-record"
+	}
+	return fmt.Sprintf("Mode(%d)", int(m))
+}
+
+// ErrNoInteraction is returned by a [Recorder] in [ModeReplay] when no
+// recorded interaction matches a request.
+var ErrNoInteraction = errors.New("chocotest: no recorded interaction matches request")
+
+// Redacted replaces secret values in recorded cassettes.
+const Redacted = "REDACTED"
+
+// Sender is the transport wrapped by a [Recorder]. Any choco Transport satisfies it.
+type Sender interface {
+	Send(*http.Request) (*http.Response, error)
+}
+
+// Interaction is a recorded request/response pair, stored as one JSON line of a cassette.
+type Interaction struct {
+	Request    RecordedRequest  `json:"request"`
+	Response   RecordedResponse `json:"response"`
+	RecordedAt time.Time        `json:"recorded_at"`
+	Duration   time.Duration    `json:"duration"`
+}
+
+// RecordedRequest is the request half of an [Interaction].
+type RecordedRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   Body        `json:"body,omitempty"`
+}
+
+// RecordedResponse is the response half of an [Interaction].
+type RecordedResponse struct {
+	StatusCode int         `json:"status"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       Body        `json:"body,omitempty"`
+}
+
+// Body holds recorded body bytes. It is stored as a JSON string when the bytes
+// are valid UTF-8, and as {"base64": "..."} otherwise.
+type Body []byte
+
+// MarshalJSON implements [json.Marshaler].
+func (b Body) MarshalJSON() ([]byte, error) {
+	if utf8.Valid(b) {
+		return json.Marshal(string(b))
+	}
+	return json.Marshal(map[string]string{"base64": base64.StdEncoding.EncodeToString(b)})
+}
+
+// UnmarshalJSON implements [json.Unmarshaler].
+func (b *Body) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*b = Body(s)
+		return nil
+	}
+	var enc struct {
+		Base64 string `json:"base64"`
+	}
+	if err := json.Unmarshal(data, &enc); err != nil {
+		return err
+	}
+	raw, err := base64.StdEncoding.DecodeString(enc.Base64)
+	*b = raw
+	return err
+}
+
+// MatcherFunc reports whether a live request (with its body already read)
+// matches a recorded one.
+type MatcherFunc func(req *http.Request, body []byte, rec RecordedRequest) bool
+
+// MatchMethodAndURL is the default matcher. It compares the method and the full URL.
+func MatchMethodAndURL(req *http.Request, _ []byte, rec RecordedRequest) bool {
+	return req.Method == rec.Method && req.URL.String() == rec.URL
+}
+
+// MatchBody compares the request bodies byte for byte.
+func MatchBody(_ *http.Request, body []byte, rec RecordedRequest) bool {
+	return bytes.Equal(body, rec.Body)
+}
+
+// MatchHeaders returns a matcher comparing the values of the given headers.
+func MatchHeaders(keys ...string) MatcherFunc {
+	return func(req *http.Request, _ []byte, rec RecordedRequest) bool {
+		for _, k := range keys {
+			if !slices.Equal(req.Header.Values(k), rec.Header.Values(k)) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// MatchAll combines matchers; a request matches when all of them agree.
+func MatchAll(matchers ...MatcherFunc) MatcherFunc {
+	return func(req *http.Request, body []byte, rec RecordedRequest) bool {
+		for _, m := range matchers {
+			if !m(req, body, rec) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// RecorderOption configures a [Recorder].
+type RecorderOption func(*Recorder)
+
+// WithMatcher replaces the default [MatchMethodAndURL] matcher.
+func WithMatcher(m MatcherFunc) RecorderOption {
+	return func(r *Recorder) {
+		r.matcher = m
+	}
+}
+
+// WithRedactedHeaders adds request and response headers whose values are
+// replaced by [Redacted] before being written. Authorization, Proxy-Authorization,
+// Cookie and Set-Cookie are always redacted.
+func WithRedactedHeaders(keys ...string) RecorderOption {
+	return func(r *Recorder) {
+		for _, k := range keys {
+			r.redactHeaders = append(r.redactHeaders, http.CanonicalHeaderKey(k))
+		}
+	}
+}
+
+// WithRedactedQuery adds query parameters whose values are replaced by
+// [Redacted] in recorded URLs. Live requests are redacted the same way before
+// being matched, so replaying needs the same option.
+func WithRedactedQuery(keys ...string) RecorderOption {
+	return func(r *Recorder) {
+		r.redactQuery = append(r.redactQuery, keys...)
+	}
+}
+
+// WithRedactFunc adds a function called on every interaction before it is
+// written, e.g. to scrub tokens from bodies.
+func WithRedactFunc(fn func(*Interaction)) RecorderOption {
+	return func(r *Recorder) {
+		r.redactFuncs = append(r.redactFuncs, fn)
+	}
+}
+
+// Recorder is a record/replay transport backed by a JSON Lines cassette file.
+// It is safe for concurrent use.
+type Recorder struct {
+	mode  Mode
+	path  string
+	inner Sender
+
+	matcher       MatcherFunc
+	redactHeaders []string
+	redactQuery   []string
+	redactFuncs   []func(*Interaction)
+
+	mu           sync.Mutex
+	interactions []Interaction
+	used         []bool
+}
+
+// NewRecorder creates a [Recorder] using the cassette at path.
+//
+// In [ModeReplay] and [ModeReplayOrRecord] the cassette is loaded; a missing
+// file is an error in replay mode and an empty cassette otherwise.
+// In [ModeRecord] the cassette is truncated. inner may be nil in [ModeReplay].
+func NewRecorder(path string, mode Mode, inner Sender, opts ...RecorderOption) (*Recorder, error) {
+	r := &Recorder{
+		mode:          mode,
+		path:          path,
+		inner:         inner,
+		matcher:       MatchMethodAndURL,
+		redactHeaders: []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"},
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if mode != ModeReplay && inner == nil {
+		return nil, fmt.Errorf("chocotest: %s mode requires an inner transport", mode)
+	}
+
+	switch mode {
+	case ModeRecord:
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			return nil, fmt.Errorf("chocotest: creating cassette: %w", err)
+		}
+	case ModeReplay, ModeReplayOrRecord:
+		interactions, err := LoadCassette(path)
+		if err != nil && !(mode == ModeReplayOrRecord && errors.Is(err, os.ErrNotExist)) {
+			return nil, err
+		}
+		r.interactions = interactions
+		r.used = make([]bool, len(interactions))
+	default:
+		return nil, fmt.Errorf("chocotest: unknown recorder mode %s", mode)
+	}
+	return r, nil
+}
+
+// LoadCassette reads every interaction of the cassette at path.
+func LoadCassette(path string) ([]Interaction, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("chocotest: opening cassette: %w", err)
+	}
+	defer f.Close()
+
+	var interactions []Interaction
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for line := 1; sc.Scan(); line++ {
+		if len(bytes.TrimSpace(sc.Bytes())) == 0 {
+			continue
+		}
+		var it Interaction
+		if err := json.Unmarshal(sc.Bytes(), &it); err != nil {
+			return nil, fmt.Errorf("chocotest: cassette %s line %d: %w", path, line, err)
+		}
+		interactions = append(interactions, it)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("chocotest: reading cassette: %w", err)
+	}
+	return interactions, nil
+}
+
+// Send implements the choco Transport interface.
+func (r *Recorder) Send(req *http.Request) (*http.Response, error) {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.mode != ModeRecord {
+		if it, ok := r.lookup(req, body); ok {
+			return it.Response.build(req), nil
+		}
+		if r.mode == ModeReplay {
+			return nil, fmt.Errorf("%w: %s %s (cassette %s)", ErrNoInteraction, req.Method, req.URL, r.path)
+		}
+	}
+	return r.record(req, body)
+}
+
+// lookup returns the first unused interaction matching req. Once every matching
+// interaction has been used, the last one is replayed again.
+func (r *Recorder) lookup(req *http.Request, body []byte) (Interaction, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Recorded requests are redacted: compare them with a redacted live request.
+	req = r.redactedRequest(req)
+	last := -1
+	for i, it := range r.interactions {
+		if !r.matcher(req, body, it.Request) {
+			continue
+		}
+		if !r.used[i] {
+			r.used[i] = true
+			return it, true
+		}
+		last = i
+	}
+	if last >= 0 {
+		return r.interactions[last], true
+	}
+	return Interaction{}, false
+}
+
+func (r *Recorder) record(req *http.Request, body []byte) (*http.Response, error) {
+	if body != nil {
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	start := time.Now()
+	resp, err := r.inner.Send(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("chocotest: reading response body: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	it := Interaction{
+		Request: RecordedRequest{
+			Method: req.Method,
+			URL:    req.URL.String(),
+			Header: req.Header.Clone(),
+			Body:   body,
+		},
+		Response: RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Header:     resp.Header.Clone(),
+			Body:       respBody,
+		},
+		RecordedAt: start.UTC(),
+		Duration:   time.Since(start),
+	}
+	if err := r.append(it); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (r *Recorder) append(it Interaction) error {
+	r.redact(&it)
+	line, err := json.Marshal(it)
+	if err != nil {
+		return fmt.Errorf("chocotest: encoding interaction: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f, err := os.OpenFile(r.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("chocotest: opening cassette: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("chocotest: writing cassette: %w", err)
+	}
+	r.interactions = append(r.interactions, it)
+	r.used = append(r.used, true)
+	return nil
+}
+
+func (r *Recorder) redact(it *Interaction) {
+	for _, k := range r.redactHeaders {
+		redactHeader(it.Request.Header, k)
+		redactHeader(it.Response.Header, k)
+	}
+	if u, err := url.Parse(it.Request.URL); err == nil {
+		if raw, ok := redactQuery(u.RawQuery, r.redactQuery); ok {
+			u.RawQuery = raw
+			it.Request.URL = u.String()
+		}
+	}
+	for _, fn := range r.redactFuncs {
+		fn(it)
+	}
+}
+
+// redactQuery replaces the values of keys in the raw query, keeping the order
+// and encoding of the other parameters. It reports whether any key was found.
+func redactQuery(raw string, keys []string) (string, bool) {
+	if raw == "" || len(keys) == 0 {
+		return raw, false
+	}
+	params := strings.Split(raw, "&")
+	found := false
+	for i, param := range params {
+		key, _, _ := strings.Cut(param, "=")
+		if k, err := url.QueryUnescape(key); err == nil && slices.Contains(keys, k) {
+			params[i] = key + "=" + Redacted
+			found = true
+		}
+	}
+	if !found {
+		return raw, false
+	}
+	return strings.Join(params, "&"), true
+}
+
+// redactedRequest returns a shallow copy of req with the headers and query
+// parameters of r redacted, to match it against recorded requests.
+func (r *Recorder) redactedRequest(req *http.Request) *http.Request {
+	view := *req
+	view.Header = req.Header.Clone()
+	for _, k := range r.redactHeaders {
+		redactHeader(view.Header, k)
+	}
+	if raw, ok := redactQuery(req.URL.RawQuery, r.redactQuery); ok {
+		u := *req.URL
+		u.RawQuery = raw
+		view.URL = &u
+	}
+	return &view
+}
+
+func redactHeader(h http.Header, key string) {
+	if values, ok := h[key]; ok {
+		for i := range values {
+			values[i] = Redacted
+		}
+	}
+}
+
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	b, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("chocotest: reading request body: %w", err)
+	}
+	return b, nil
+}
+
+func (rr RecordedResponse) build(req *http.Request) *http.Response {
+	return Response{
+		StatusCode: rr.StatusCode,
+		Header:     rr.Header,
+		Body:       bytes.Clone(rr.Body),
+	}.build(req)
+}
@@ -0,0 +1,114 @@
+package chocotest_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"nyxze/choco-go/chocotest"
+)
+
+func TestRecorderRecordThenReplay(t *testing.T) {
+	cassette := filepath.Join(t.TempDir(), "cassette.jsonl")
+	ctx := context.Background()
+
+	live := chocotest.NewTransport()
+	live.On("POST", "/login").Respond(chocotest.Text(http.StatusOK, "welcome").WithHeader("Set-Cookie", "session=secret"))
+	live.On("GET", "/binary").Respond(chocotest.Response{Body: []byte{0xff, 0x00, 0xfe}})
+
+	rec, err := chocotest.NewRecorder(cassette, chocotest.ModeRecord, live, chocotest.WithRedactedQuery("api_key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	login := newRequest(t, ctx, "POST", "http://x/login", `{"user":"clou"}`)
+	login.Header.Set("Authorization", "Bearer secret")
+	resp, err := rec.Send(login)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := io.ReadAll(resp.Body); string(b) != "welcome" {
+		t.Errorf("recorded response body = %q", b)
+	}
+	if got := resp.Header.Get("Set-Cookie"); got != "session=secret" {
+		t.Errorf("live response should not be redacted, got %q", got)
+	}
+	if _, err := rec.Send(newRequest(t, ctx, "GET", "http://x/binary?b=1&api_key=secret", "")); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := os.ReadFile(cassette)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(raw), "secret") {
+		t.Errorf("cassette leaks secrets:\n%s", raw)
+	}
+	if n := strings.Count(string(raw), "\n"); n != 2 {
+		t.Errorf("cassette has %d lines, want 2", n)
+	}
+
+	if !strings.Contains(string(raw), `/binary?b=1\u0026api_key=REDACTED`) {
+		t.Errorf("redacted query should keep its order:\n%s", raw)
+	}
+
+	replay, err := chocotest.NewRecorder(cassette, chocotest.ModeReplay, nil, chocotest.WithRedactedQuery("api_key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err = replay.Send(newRequest(t, ctx, "POST", "http://x/login", `{"user":"clou"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := io.ReadAll(resp.Body); string(b) != "welcome" {
+		t.Errorf("replayed body = %q", b)
+	}
+	resp, err = replay.Send(newRequest(t, ctx, "GET", "http://x/binary?b=1&api_key=secret", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := io.ReadAll(resp.Body); string(b) != "\xff\x00\xfe" {
+		t.Errorf("replayed binary body = %q", b)
+	}
+
+	_, err = replay.Send(newRequest(t, ctx, "GET", "http://x/unknown", ""))
+	if !errors.Is(err, chocotest.ErrNoInteraction) {
+		t.Errorf("err = %v, want ErrNoInteraction", err)
+	}
+	if err != nil && !strings.Contains(err.Error(), "GET http://x/unknown") {
+		t.Errorf("error should describe the request: %v", err)
+	}
+}
+
+func TestRecorderReplayOrRecord(t *testing.T) {
+	cassette := filepath.Join(t.TempDir(), "cassette.jsonl")
+	ctx := context.Background()
+
+	live := chocotest.NewTransport()
+	live.On("GET", "/a").Once().Respond(chocotest.Text(http.StatusOK, "a"))
+
+	matcher := chocotest.MatchAll(chocotest.MatchMethodAndURL, chocotest.MatchBody)
+	for range 2 {
+		rec, err := chocotest.NewRecorder(cassette, chocotest.ModeReplayOrRecord, live, chocotest.WithMatcher(matcher))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := rec.Send(newRequest(t, ctx, "GET", "http://x/a", ""))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b, _ := io.ReadAll(resp.Body); string(b) != "a" {
+			t.Errorf("body = %q", b)
+		}
+	}
+	// The second run was served from the cassette.
+	live.AssertExpectations(t)
+
+	if _, err := chocotest.NewRecorder(filepath.Join(t.TempDir(), "missing.jsonl"), chocotest.ModeReplay, nil); err == nil {
+		t.Error("expected error for missing cassette in replay mode")
+	}
+}
@@ -0,0 +1,79 @@
+package chocotest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Status returns an empty response with the given status code.
+func Status(code int) Response {
+	return Response{StatusCode: code}
+}
+
+// Text returns a text/plain response.
+func Text(code int, body string) Response {
+	return Response{
+		StatusCode: code,
+		Header:     http.Header{"Content-Type": {"text/plain; charset=utf-8"}},
+		Body:       []byte(body),
+	}
+}
+
+// JSON returns an application/json response holding the encoding of v.
+// It panics if v cannot be marshalled.
+func JSON(code int, v any) Response {
+	b, err := json.Marshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("chocotest: cannot marshal JSON response: %v", err))
+	}
+	return Response{
+		StatusCode: code,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       b,
+	}
+}
+
+// Error returns a response that makes Send fail with err.
+func Error(err error) Response {
+	return Response{Err: err}
+}
+
+// Event is a single server-sent event.
+type Event struct {
+	ID    string
+	Event string
+	Data  string
+	Retry int
+}
+
+// SSE returns a 200 text/event-stream response carrying the given events.
+// Multi-line data is split over several "data:" lines.
+func SSE(events ...Event) Response {
+	var sb strings.Builder
+	for _, e := range events {
+		if e.ID != "" {
+			sb.WriteString("id: " + e.ID + "\n")
+		}
+		if e.Event != "" {
+			sb.WriteString("event: " + e.Event + "\n")
+		}
+		if e.Retry > 0 {
+			sb.WriteString("retry: " + strconv.Itoa(e.Retry) + "\n")
+		}
+		for line := range strings.SplitSeq(e.Data, "\n") {
+			sb.WriteString("data: " + line + "\n")
+		}
+		sb.WriteString("\n")
+	}
+	return Response{
+		StatusCode: http.StatusOK,
+		Header: http.Header{
+			"Content-Type":  {"text/event-stream"},
+			"Cache-Control": {"no-cache"},
+		},
+		Body: []byte(sb.String()),
+	}
+}
@@ -0,0 +1,217 @@
+// Package chocotest provides a scriptable mock Transport for testing code
+// built on choco pipelines.
+//
+// A [Transport] holds a list of expectations. Each expectation matches
+// requests by method, path, query, headers and JSON body, and answers
+// them with scripted responses:
+//
+//	tr := chocotest.NewTransport()
+//	tr.On("GET", "/users/42").
+//	    WithHeader("Authorization", "Bearer token").
+//	    Respond(chocotest.JSON(http.StatusOK, user))
+//	tr.On("POST", "/users").
+//	    WithJSONBody(map[string]any{"name": "clou"}).
+//	    Respond(chocotest.Status(http.StatusServiceUnavailable), chocotest.Status(http.StatusCreated))
+//
+//	pipeline, _ := choco.NewPipeline(choco.WithCustomTransport(tr))
+//	// ... exercise the code under test ...
+//	tr.AssertExpectations(t)
+//
+// A [Recorder] wraps a real transport to record traffic into a JSON Lines
+// cassette and serve it back later, so integration tests can run offline.
+package chocotest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Transport is a mock choco Transport. It is safe for concurrent use.
+type Transport struct {
+	mu           sync.Mutex
+	expectations []*Expectation
+	calls        []Call
+}
+
+// Call records a request received by a [Transport].
+type Call struct {
+	// Request is a clone of the received request. Its body has been consumed;
+	// use Body instead.
+	Request *http.Request
+	// Body holds the request body bytes, if any.
+	Body []byte
+	// Expectation is the expectation that answered the request,
+	// or nil when no expectation matched.
+	Expectation *Expectation
+}
+
+// NewTransport returns a [Transport] without expectations.
+func NewTransport() *Transport {
+	return &Transport{}
+}
+
+// On registers a new expectation for requests with the given method and path.
+// An empty method or a method of "*" matches any method. A path ending with "*"
+// matches any path with that prefix, and an empty path matches any path.
+//
+// Expectations are evaluated in registration order; the first one that matches
+// and has responses left answers the request.
+func (t *Transport) On(method, path string) *Expectation {
+	e := &Expectation{
+		method: strings.ToUpper(method),
+		path:   path,
+		query:  map[string][]string{},
+		header: http.Header{},
+		times:  -1,
+	}
+	t.mu.Lock()
+	t.expectations = append(t.expectations, e)
+	t.mu.Unlock()
+	return e
+}
+
+// Send implements the choco Transport interface.
+func (t *Transport) Send(req *http.Request) (*http.Response, error) {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	var (
+		matched *Expectation
+		resp    Response
+	)
+	for _, e := range t.expectations {
+		if e.exhausted() || !e.matches(req, body) {
+			continue
+		}
+		matched = e
+		resp = e.next()
+		break
+	}
+	t.calls = append(t.calls, Call{
+		Request:     req.Clone(context.WithoutCancel(req.Context())),
+		Body:        body,
+		Expectation: matched,
+	})
+	t.mu.Unlock()
+
+	if matched == nil {
+		return nil, fmt.Errorf("chocotest: no expectation matches %s %s", req.Method, req.URL)
+	}
+	if resp.Delay > 0 {
+		timer := time.NewTimer(resp.Delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+	if resp.Err != nil {
+		return nil, resp.Err
+	}
+	return resp.build(req), nil
+}
+
+// Calls returns the requests received so far, in arrival order.
+func (t *Transport) Calls() []Call {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]Call(nil), t.calls...)
+}
+
+// Reset removes all expectations and recorded calls.
+func (t *Transport) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.expectations = nil
+	t.calls = nil
+}
+
+// AssertExpectations reports a test error for every expectation that was not
+// used as many times as required, and for every request that matched nothing.
+// It returns true when all expectations were met.
+func (t *Transport) AssertExpectations(tb testing.TB) bool {
+	tb.Helper()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ok := true
+	for _, e := range t.expectations {
+		switch {
+		case e.times < 0 && e.calls == 0:
+			tb.Errorf("chocotest: expectation %s was never called", e)
+			ok = false
+		case e.times >= 0 && e.calls != e.times:
+			tb.Errorf("chocotest: expectation %s called %d times, want %d", e, e.calls, e.times)
+			ok = false
+		}
+	}
+	for _, c := range t.calls {
+		if c.Expectation == nil {
+			tb.Errorf("chocotest: unexpected request %s %s", c.Request.Method, c.Request.URL)
+			ok = false
+		}
+	}
+	return ok
+}
+
+// Response describes a scripted answer to a request.
+type Response struct {
+	// StatusCode defaults to 200 when zero.
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	// Err, when set, is returned by Send instead of a response.
+	Err error
+	// Delay is waited before answering. It is cut short if the
+	// request context is done, in which case the context error is returned.
+	Delay time.Duration
+}
+
+// WithHeader returns a copy of r with the header key set to value.
+func (r Response) WithHeader(key, value string) Response {
+	r.Header = r.Header.Clone()
+	if r.Header == nil {
+		r.Header = http.Header{}
+	}
+	r.Header.Set(key, value)
+	return r
+}
+
+// WithDelay returns a copy of r that is answered after d.
+func (r Response) WithDelay(d time.Duration) Response {
+	r.Delay = d
+	return r
+}
+
+func (r Response) build(req *http.Request) *http.Response {
+	status := r.StatusCode
+	if status == 0 {
+		status = http.StatusOK
+	}
+	header := r.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(r.Body)),
+		ContentLength: int64(len(r.Body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,159 @@
+package chocotest_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"nyxze/choco-go/chocotest"
+)
+
+func newRequest(t *testing.T, ctx context.Context, method, url, body string) *http.Request {
+	t.Helper()
+	var r io.Reader
+	if body != "" {
+		r = strings.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
+
+func TestTransportMatching(t *testing.T) {
+	tr := chocotest.NewTransport()
+	tr.On("GET", "/users/*").
+		WithQuery("page", "2").
+		WithHeader("Authorization", "Bearer token").
+		Respond(chocotest.Text(http.StatusOK, "users"))
+	tr.On("POST", "/users").
+		WithJSONBody(`{"name":"clou","age":21}`).
+		Respond(chocotest.Status(http.StatusServiceUnavailable), chocotest.Status(http.StatusCreated))
+
+	ctx := context.Background()
+	get := newRequest(t, ctx, "GET", "http://api.example.com/users/42?page=2", "")
+	get.Header.Set("Authorization", "Bearer token")
+	resp, err := tr.Send(get)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := io.ReadAll(resp.Body); string(b) != "users" {
+		t.Errorf("body = %q, want %q", b, "users")
+	}
+
+	var statuses []int
+	for range 3 {
+		resp, err := tr.Send(newRequest(t, ctx, "POST", "http://api.example.com/users", `{"age": 21, "name": "clou"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		statuses = append(statuses, resp.StatusCode)
+	}
+	if want := []int{503, 201, 201}; !equalInts(statuses, want) {
+		t.Errorf("statuses = %v, want %v", statuses, want)
+	}
+
+	if _, err := tr.Send(newRequest(t, ctx, "GET", "http://api.example.com/users/42", "")); err == nil {
+		t.Error("expected error for request without required query and header")
+	}
+
+	calls := tr.Calls()
+	if len(calls) != 5 {
+		t.Fatalf("recorded %d calls, want 5", len(calls))
+	}
+	if string(calls[1].Body) != `{"age": 21, "name": "clou"}` {
+		t.Errorf("recorded body = %q", calls[1].Body)
+	}
+	if calls[4].Expectation != nil {
+		t.Error("unmatched call should have no expectation")
+	}
+
+	rec := &recorder{TB: t}
+	if tr.AssertExpectations(rec) {
+		t.Error("AssertExpectations should fail because of the unmatched request")
+	}
+	if len(rec.errors) != 1 {
+		t.Errorf("got errors %q, want one unmatched request", rec.errors)
+	}
+}
+
+func TestTransportTimesAndErrors(t *testing.T) {
+	tr := chocotest.NewTransport()
+	injected := errors.New("connection reset")
+	tr.On("GET", "/flaky").Once().RespondError(injected)
+	tr.On("GET", "/flaky").Once().Respond(chocotest.Status(http.StatusOK))
+	tr.On("DELETE", "").Times(2)
+
+	ctx := context.Background()
+	if _, err := tr.Send(newRequest(t, ctx, "GET", "http://x/flaky", "")); !errors.Is(err, injected) {
+		t.Errorf("err = %v, want %v", err, injected)
+	}
+	if resp, err := tr.Send(newRequest(t, ctx, "GET", "http://x/flaky", "")); err != nil || resp.StatusCode != 200 {
+		t.Errorf("second call = %v, %v", resp, err)
+	}
+	if _, err := tr.Send(newRequest(t, ctx, "DELETE", "http://x/anything", "")); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := &recorder{TB: t}
+	tr.AssertExpectations(rec)
+	if len(rec.errors) != 1 || !strings.Contains(rec.errors[0], "DELETE * called 1 times, want 2") {
+		t.Errorf("errors = %q", rec.errors)
+	}
+}
+
+func TestTransportDelay(t *testing.T) {
+	tr := chocotest.NewTransport()
+	tr.On("GET", "/slow").Respond(chocotest.Status(http.StatusOK).WithDelay(time.Second))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := tr.Send(newRequest(t, ctx, "GET", "http://x/slow", ""))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want deadline exceeded", err)
+	}
+}
+
+func TestSSEResponse(t *testing.T) {
+	resp := chocotest.SSE(
+		chocotest.Event{Event: "start", Data: "hello"},
+		chocotest.Event{ID: "2", Data: "a\nb"},
+	)
+	want := "event: start\ndata: hello\n\nid: 2\ndata: a\ndata: b\n\n"
+	if string(resp.Body) != want {
+		t.Errorf("body = %q, want %q", resp.Body, want)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+}
+
+// recorder captures Errorf calls instead of failing the test.
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
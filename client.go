@@ -0,0 +1,123 @@
+package choco
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// [HTTPClientOptions] configures the [http.Client] built by [WithHTTPClientOptions].
+// Zero fields keep the defaults of [http.DefaultTransport].
+type HTTPClientOptions struct {
+	// Timeout limits the whole exchange, including reading the response body.
+	// Zero means no timeout.
+	Timeout time.Duration
+
+	// DialTimeout limits the time to establish a connection.
+	DialTimeout time.Duration
+	// KeepAlive is the interval of TCP keep-alive probes.
+	KeepAlive time.Duration
+	// TLSHandshakeTimeout limits the time of the TLS handshake.
+	TLSHandshakeTimeout time.Duration
+	// ResponseHeaderTimeout limits the wait for the response headers once the
+	// request is written.
+	ResponseHeaderTimeout time.Duration
+
+	// MaxIdleConns limits the idle connections kept across all hosts.
+	MaxIdleConns int
+	// MaxIdleConnsPerHost limits the idle connections kept for each host.
+	MaxIdleConnsPerHost int
+	// MaxConnsPerHost limits the connections to each host, in any state.
+	MaxConnsPerHost int
+	// IdleConnTimeout closes connections idle for longer.
+	IdleConnTimeout time.Duration
+
+	// Proxy selects the proxy of a request, as [http.ProxyURL] does. When nil,
+	// proxies are taken from the environment ([http.ProxyFromEnvironment]).
+	Proxy func(*http.Request) (*url.URL, error)
+	// DisableProxy ignores Proxy and the environment, connecting directly.
+	DisableProxy bool
+
+	// TLSConfig is the base TLS configuration. It is cloned, never modified.
+	TLSConfig *tls.Config
+	// RootCAs replaces the system certificate pool to verify servers.
+	RootCAs *x509.CertPool
+	// Certificates are presented to servers requesting a client certificate.
+	Certificates []tls.Certificate
+
+	// DisableHTTP2 restricts connections to HTTP/1.1.
+	DisableHTTP2 bool
+}
+
+// newHTTPClient builds a client with its own transport, and so its own connection pool.
+func newHTTPClient(opts HTTPClientOptions) *http.Client {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	if opts.DialTimeout > 0 {
+		dialer.Timeout = opts.DialTimeout
+	}
+	if opts.KeepAlive != 0 {
+		dialer.KeepAlive = opts.KeepAlive
+	}
+	t.DialContext = dialer.DialContext
+
+	if opts.TLSHandshakeTimeout > 0 {
+		t.TLSHandshakeTimeout = opts.TLSHandshakeTimeout
+	}
+	if opts.ResponseHeaderTimeout > 0 {
+		t.ResponseHeaderTimeout = opts.ResponseHeaderTimeout
+	}
+	if opts.MaxIdleConns > 0 {
+		t.MaxIdleConns = opts.MaxIdleConns
+	}
+	if opts.MaxIdleConnsPerHost > 0 {
+		t.MaxIdleConnsPerHost = opts.MaxIdleConnsPerHost
+	}
+	if opts.MaxConnsPerHost > 0 {
+		t.MaxConnsPerHost = opts.MaxConnsPerHost
+	}
+	if opts.IdleConnTimeout > 0 {
+		t.IdleConnTimeout = opts.IdleConnTimeout
+	}
+
+	switch {
+	case opts.DisableProxy:
+		t.Proxy = nil
+	case opts.Proxy != nil:
+		t.Proxy = opts.Proxy
+	}
+
+	if opts.TLSConfig != nil || opts.RootCAs != nil || len(opts.Certificates) > 0 {
+		cfg := &tls.Config{}
+		if opts.TLSConfig != nil {
+			cfg = opts.TLSConfig.Clone()
+		}
+		if opts.RootCAs != nil {
+			cfg.RootCAs = opts.RootCAs
+		}
+		if len(opts.Certificates) > 0 {
+			cfg.Certificates = append(cfg.Certificates, opts.Certificates...)
+		}
+		t.TLSClientConfig = cfg
+	}
+
+	if opts.DisableHTTP2 {
+		protocols := new(http.Protocols)
+		protocols.SetHTTP1(true)
+		t.Protocols = protocols
+	}
+
+	return &http.Client{Transport: t, Timeout: opts.Timeout}
+}
+
+// CloseIdleConnections closes the idle connections of the [http.Client] owned by
+// the pipeline, as built by [WithHTTPClientOptions]. It does nothing for other transports.
+func (p Pipeline) CloseIdleConnections() {
+	if t, ok := p.transport.(defaultTransport); ok && t.client != http.DefaultClient {
+		t.client.CloseIdleConnections()
+	}
+}
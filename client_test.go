@@ -0,0 +1,112 @@
+package choco
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func get(t *testing.T, p Pipeline, url string) (*http.Response, string, error) {
+	t.Helper()
+	req, err := NewRequest(context.Background(), "GET", url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := p.Execute(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	return resp, string(b), err
+}
+
+func TestWithHTTPClientOptionsTLS(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s certs=%d", r.Proto, len(r.TLS.PeerCertificates))
+	}))
+	srv.EnableHTTP2 = true
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0) // Failed handshakes are expected
+	srv.TLS = &tls.Config{ClientAuth: tls.RequestClientCert}
+	srv.StartTLS()
+	defer srv.Close()
+	cas := srv.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs
+
+	tests := []struct {
+		name    string
+		opts    HTTPClientOptions
+		want    string
+		wantErr bool
+	}{
+		{"unknown CA", HTTPClientOptions{}, "", true},
+		{"custom CA", HTTPClientOptions{RootCAs: cas}, "HTTP/2.0 certs=0", false},
+		{"HTTP/1.1 only", HTTPClientOptions{RootCAs: cas, DisableHTTP2: true}, "HTTP/1.1 certs=0", false},
+		{"client certificate", HTTPClientOptions{
+			TLSConfig:    &tls.Config{RootCAs: cas},
+			Certificates: srv.TLS.Certificates,
+		}, "HTTP/2.0 certs=1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewPipeline(WithHTTPClientOptions(tt.opts))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer p.CloseIdleConnections()
+			_, body, err := get(t, p, srv.URL)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if body != tt.want {
+				t.Errorf("body = %q, want %q", body, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithHTTPClientOptionsProxyAndTimeouts(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "proxied %s", r.URL)
+	}))
+	defer proxy.Close()
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer slow.Close()
+	proxyURL, _ := url.Parse(proxy.URL)
+
+	p, err := NewPipeline(WithHTTPClientOptions(HTTPClientOptions{Proxy: http.ProxyURL(proxyURL)}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, body, err := get(t, p, "http://upstream.example.com/x"); err != nil || body != "proxied http://upstream.example.com/x" {
+		t.Errorf("proxy: body = %q, err = %v", body, err)
+	}
+
+	p, err = NewPipeline(WithHTTPClientOptions(HTTPClientOptions{ResponseHeaderTimeout: 20 * time.Millisecond}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if _, _, err := get(t, p, slow.URL); err == nil || time.Since(start) > 500*time.Millisecond {
+		t.Errorf("response header timeout: err = %v after %s", err, time.Since(start))
+	}
+
+	// The pipeline owns its client: the default client is left untouched.
+	if http.DefaultClient.Timeout != 0 || http.DefaultClient.Transport != nil {
+		t.Error("http.DefaultClient was modified")
+	}
+	if _, err := NewPipeline(WithHTTPClientOptions(HTTPClientOptions{Timeout: -time.Second})); err == nil {
+		t.Error("expected error for a negative timeout")
+	}
+}
@@ -0,0 +1,369 @@
+package choco
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"maps"
+	"mime"
+	"net/http"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Codec encodes and decodes bodies of one or more content types.
+type Codec interface {
+	// ContentTypes lists the media types handled by the codec, without
+	// parameters. The first one is used as the Content-Type of encoded bodies.
+	ContentTypes() []string
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// CodecRegistry maps media types to [Codec]s.
+// A registry must not be modified while it is used by a [Pipeline].
+type CodecRegistry struct {
+	codecs []Codec
+	byType map[string]Codec
+}
+
+// NewCodecRegistry returns a registry holding codecs, in priority order.
+func NewCodecRegistry(codecs ...Codec) *CodecRegistry {
+	r := &CodecRegistry{byType: map[string]Codec{}}
+	for _, c := range codecs {
+		r.Register(c)
+	}
+	return r
+}
+
+// DefaultCodecs returns a registry with the JSON, XML, text and form codecs.
+func DefaultCodecs() *CodecRegistry {
+	return NewCodecRegistry(JSONCodec{}, XMLCodec{}, TextCodec{}, FormCodec{})
+}
+
+// Register adds c to the registry. A codec registered for a media type that
+// is already known replaces the previous one for that type.
+func (r *CodecRegistry) Register(c Codec) {
+	i := slices.IndexFunc(r.codecs, func(old Codec) bool {
+		return slices.Equal(old.ContentTypes(), c.ContentTypes())
+	})
+	if i >= 0 {
+		r.codecs[i] = c
+	} else {
+		r.codecs = append(r.codecs, c)
+	}
+	for _, t := range c.ContentTypes() {
+		r.byType[strings.ToLower(t)] = c
+	}
+}
+
+// Clone returns a copy of the registry that can be modified independently.
+func (r *CodecRegistry) Clone() *CodecRegistry {
+	return &CodecRegistry{
+		codecs: slices.Clone(r.codecs),
+		byType: maps.Clone(r.byType),
+	}
+}
+
+// Lookup returns the codec for a Content-Type value. Parameters are ignored,
+// and structured syntax suffixes fall back to their base type, so
+// "application/problem+json; charset=utf-8" resolves to the JSON codec.
+func (r *CodecRegistry) Lookup(contentType string) (Codec, bool) {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, false
+	}
+	if c, ok := r.byType[mt]; ok {
+		return c, true
+	}
+	if i := strings.LastIndexByte(mt, '+'); i >= 0 {
+		typ, _, _ := strings.Cut(mt, "/")
+		if c, ok := r.byType[typ+"/"+mt[i+1:]]; ok {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// Negotiate picks a codec for an Accept header value. Media ranges are tried
+// by decreasing quality value, and wildcards ("text/*", "*/*") match the first
+// registered codec of that range. It also returns the media type chosen.
+func (r *CodecRegistry) Negotiate(accept string) (Codec, string, bool) {
+	for _, mr := range parseAccept(accept) {
+		if !strings.Contains(mr, "*") {
+			if c, ok := r.Lookup(mr); ok {
+				return c, mr, true
+			}
+			continue
+		}
+		// Prefer codecs whose main content type is in range over aliases.
+		typ, _, _ := strings.Cut(mr, "/")
+		for _, primaryOnly := range []bool{true, false} {
+			for _, c := range r.codecs {
+				types := c.ContentTypes()
+				if primaryOnly {
+					types = types[:1]
+				}
+				for _, t := range types {
+					if typ == "*" || strings.HasPrefix(t, typ+"/") {
+						return c, t, true
+					}
+				}
+			}
+		}
+	}
+	return nil, "", false
+}
+
+// parseAccept returns the media ranges of an Accept header, sorted by
+// decreasing quality value. Ranges with q=0 are dropped.
+func parseAccept(accept string) []string {
+	type mediaRange struct {
+		typ string
+		q   float64
+	}
+	var ranges []mediaRange
+	for part := range strings.SplitSeq(accept, ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		if q > 0 {
+			ranges = append(ranges, mediaRange{mt, q})
+		}
+	}
+	slices.SortStableFunc(ranges, func(a, b mediaRange) int {
+		switch {
+		case a.q > b.q:
+			return -1
+		case a.q < b.q:
+			return 1
+		}
+		return 0
+	})
+	types := make([]string, len(ranges))
+	for i, r := range ranges {
+		types[i] = r.typ
+	}
+	return types
+}
+
+// SetBodyAs sets v as the request body, to be encoded by the [Pipeline]'s
+// codecs when the request is executed.
+//
+// The codec is chosen from the Content-Type header of the request; when it is
+// not set, the first codec of the registry (JSON by default) is used and its
+// content type is set on the request.
+//
+// Like [Request.SetBody], it replaces any body set before.
+func (r *Request) SetBodyAs(v any) {
+	raw := r.Raw()
+	raw.Body = nil
+	raw.GetBody = nil
+	raw.ContentLength = 0
+	r.DelHeader(HeaderContentLength)
+	r.body = nil
+	r.stream = nil
+	r.bodyValue = &v
+}
+
+// withEncodedBody returns r, or when a value was set with SetBodyAs, a clone of
+// r with the value encoded by the default codecs. It is used by exports that
+// run outside of a [Pipeline].
+func (r *Request) withEncodedBody() (*Request, error) {
+	if r.bodyValue == nil {
+		return r, nil
+	}
+	c, err := r.Clone(r.req.Context())
+	if err != nil {
+		return nil, err
+	}
+	if err := c.encodeBodyValue(DefaultCodecs()); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// encodeBodyValue encodes a value set with SetBodyAs using codecs.
+func (r *Request) encodeBodyValue(codecs *CodecRegistry) error {
+	if r.bodyValue == nil {
+		return nil
+	}
+	v := *r.bodyValue
+
+	var (
+		codec       Codec
+		contentType = r.req.Header.Get(HeaderContentType)
+	)
+	if contentType != "" {
+		c, ok := codecs.Lookup(contentType)
+		if !ok {
+			return NewError("codec: no codec registered for content type %q", contentType)
+		}
+		codec = c
+	} else {
+		if len(codecs.codecs) == 0 {
+			return NewError("codec: no codec registered")
+		}
+		codec = codecs.codecs[0]
+		contentType = codec.ContentTypes()[0]
+	}
+
+	b, err := codec.Marshal(v)
+	if err != nil {
+		return NewError("codec: error marshalling type %T as %s: %v", v, contentType, err)
+	}
+	if err := r.SetBody(NopCloser(bytes.NewReader(b)), contentType); err != nil {
+		return err
+	}
+	r.bodyValue = nil
+	return nil
+}
+
+// DecodeResponse reads and closes the body of resp and decodes it into v.
+//
+// The codec is picked from the response Content-Type. When the response has no
+// Content-Type, or one without a registered codec, the Accept header of the
+// request is negotiated instead.
+func (p Pipeline) DecodeResponse(resp *http.Response, v any) error {
+	if resp == nil || resp.Body == nil {
+		return NewError("codec: response has no body")
+	}
+	defer resp.Body.Close()
+
+	codecs := p.codecs
+	if codecs == nil {
+		codecs = DefaultCodecs()
+	}
+	codec, ok := codecs.Lookup(resp.Header.Get(HeaderContentType))
+	if !ok && resp.Request != nil {
+		codec, _, ok = codecs.Negotiate(resp.Request.Header.Get(HeaderAccept))
+	}
+	if !ok {
+		return NewError("codec: no codec for response content type %q", resp.Header.Get(HeaderContentType))
+	}
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return codec.Unmarshal(b, v)
+}
+
+// JSONCodec encodes bodies with encoding/json.
+type JSONCodec struct{}
+
+func (JSONCodec) ContentTypes() []string             { return []string{ContentTypeAppJSON} }
+func (JSONCodec) Marshal(v any) ([]byte, error)      { return json.Marshal(v) }
+func (JSONCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+
+// XMLCodec encodes bodies with encoding/xml.
+type XMLCodec struct{}
+
+func (XMLCodec) ContentTypes() []string             { return []string{ContentTypeAppXML, "text/xml"} }
+func (XMLCodec) Marshal(v any) ([]byte, error)      { return xml.Marshal(v) }
+func (XMLCodec) Unmarshal(data []byte, v any) error { return xml.Unmarshal(data, v) }
+
+// TextCodec encodes plain text bodies. It marshals strings, byte slices,
+// [encoding.TextMarshaler] and [fmt.Stringer] values, and unmarshals into
+// *string, *[]byte and [encoding.TextUnmarshaler] values.
+type TextCodec struct{}
+
+func (TextCodec) ContentTypes() []string { return []string{ContentTypeTextPlain} }
+
+func (TextCodec) Marshal(v any) ([]byte, error) {
+	switch x := v.(type) {
+	case string:
+		return []byte(x), nil
+	case []byte:
+		return x, nil
+	case encoding.TextMarshaler:
+		return x.MarshalText()
+	case fmt.Stringer:
+		return []byte(x.String()), nil
+	}
+	return nil, fmt.Errorf("text codec cannot marshal %T", v)
+}
+
+func (TextCodec) Unmarshal(data []byte, v any) error {
+	switch x := v.(type) {
+	case *string:
+		*x = string(data)
+	case *[]byte:
+		*x = bytes.Clone(data)
+	case encoding.TextUnmarshaler:
+		return x.UnmarshalText(data)
+	default:
+		return fmt.Errorf("text codec cannot unmarshal into %T", v)
+	}
+	return nil
+}
+
+// FormCodec encodes application/x-www-form-urlencoded bodies. It marshals
+// [url.Values], map[string]string, map[string][]string and structs (using the
+// `query` tags of [Request.SetQueryStruct]), and unmarshals into *url.Values,
+// *map[string]string and *map[string][]string.
+type FormCodec struct{}
+
+func (FormCodec) ContentTypes() []string { return []string{ContentTypeFormURLEncoded} }
+
+func (FormCodec) Marshal(v any) ([]byte, error) {
+	switch x := v.(type) {
+	case url.Values:
+		return []byte(x.Encode()), nil
+	case map[string][]string:
+		return []byte(url.Values(x).Encode()), nil
+	case map[string]string:
+		values := url.Values{}
+		for k, s := range x {
+			values.Set(k, s)
+		}
+		return []byte(values.Encode()), nil
+	}
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("form codec cannot marshal %T", v)
+	}
+	values, err := encodeQuery(v)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(values.Encode()), nil
+}
+
+func (FormCodec) Unmarshal(data []byte, v any) error {
+	values, err := url.ParseQuery(string(data))
+	if err != nil {
+		return err
+	}
+	switch x := v.(type) {
+	case *url.Values:
+		*x = values
+	case *map[string][]string:
+		*x = values
+	case *map[string]string:
+		m := make(map[string]string, len(values))
+		for k := range values {
+			m[k] = values.Get(k)
+		}
+		*x = m
+	default:
+		return fmt.Errorf("form codec cannot unmarshal into %T", v)
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package choco
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"nyxze/choco-go/chocotest"
+)
+
+type codecUser struct {
+	XMLName xml.Name `json:"-" xml:"user" query:"-"`
+	Name    string   `json:"name" xml:"name" query:"name"`
+	Age     int      `json:"age" xml:"age" query:"age"`
+}
+
+// upperCodec is a custom codec for text/x-upper bodies.
+type upperCodec struct{}
+
+func (upperCodec) ContentTypes() []string { return []string{"text/x-upper"} }
+func (upperCodec) Marshal(v any) ([]byte, error) {
+	return []byte(strings.ToUpper(v.(string))), nil
+}
+func (upperCodec) Unmarshal(data []byte, v any) error {
+	*v.(*string) = strings.ToLower(string(data))
+	return nil
+}
+
+func TestSetBodyAs(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		value       any
+		wantType    string
+		wantBody    string
+	}{
+		{"default json", "", codecUser{Name: "clou", Age: 21}, ContentTypeAppJSON, `{"name":"clou","age":21}`},
+		{"xml", ContentTypeAppXML, codecUser{Name: "clou", Age: 21}, ContentTypeAppXML, `<user><name>clou</name><age>21</age></user>`},
+		{"form struct", ContentTypeFormURLEncoded, codecUser{Name: "clou", Age: 21}, ContentTypeFormURLEncoded, `age=21&name=clou`},
+		{"text", "text/plain; charset=utf-8", "kweh", "text/plain; charset=utf-8", "kweh"},
+		{"custom codec", "text/x-upper", "kweh", "text/x-upper", "KWEH"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := pingTransport()
+			p, err := NewPipeline(WithCustomTransport(tr), WithCodecs(upperCodec{}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req, err := NewRequest(context.Background(), "POST", testURL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.contentType != "" {
+				req.SetContentType(tt.contentType)
+			}
+			req.SetBodyAs(tt.value)
+			if _, err := p.Execute(req); err != nil {
+				t.Fatal(err)
+			}
+			call := tr.Calls()[0]
+			if got := call.Request.Header.Get(HeaderContentType); got != tt.wantType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.wantType)
+			}
+			if string(call.Body) != tt.wantBody {
+				t.Errorf("body = %q, want %q", call.Body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestSetBodyAsReplacesBody(t *testing.T) {
+	tr := pingTransport()
+	p, err := NewPipeline(WithCustomTransport(tr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := NewRequest(context.Background(), "POST", testURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := req.SetStreamBody(strings.NewReader("stale"), 5, ""); err != nil {
+		t.Fatal(err)
+	}
+	req.SetBodyAs(codecUser{Name: "clou", Age: 21})
+	if req.Body() != nil || req.Raw().Body != nil {
+		t.Fatal("SetBodyAs kept the previous body")
+	}
+	// Without a stream body, the request can now be cloned.
+	if _, err := req.Clone(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Execute(req); err != nil {
+		t.Fatal(err)
+	}
+	if body := string(tr.Calls()[0].Body); body != `{"name":"clou","age":21}` {
+		t.Errorf("body = %q", body)
+	}
+}
+
+func TestSetBodyAsUnknownContentType(t *testing.T) {
+	p, err := NewPipeline(WithCustomTransport(pingTransport()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := NewRequest(context.Background(), "POST", testURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetContentType("application/x-unknown")
+	req.SetBodyAs(1)
+	if _, err := p.Execute(req); err == nil {
+		t.Error("expected error for content type without codec")
+	}
+}
+
+func TestDecodeResponse(t *testing.T) {
+	tests := []struct {
+		name     string
+		response chocotest.Response
+		accept   string
+		check    func(t *testing.T, p Pipeline, resp *http.Response)
+	}{
+		{
+			name:     "json with structured suffix",
+			response: chocotest.Response{Header: http.Header{HeaderContentType: {"application/problem+json; charset=utf-8"}}, Body: []byte(`{"name":"clou","age":21}`)},
+			check: func(t *testing.T, p Pipeline, resp *http.Response) {
+				var u codecUser
+				if err := p.DecodeResponse(resp, &u); err != nil || u.Name != "clou" || u.Age != 21 {
+					t.Errorf("decoded %+v, %v", u, err)
+				}
+			},
+		},
+		{
+			name:     "missing content type falls back to accept",
+			response: chocotest.Response{Body: []byte(`<user><name>clou</name><age>21</age></user>`)},
+			accept:   "application/json;q=0.5, application/xml;q=0.9, */*;q=0.1",
+			check: func(t *testing.T, p Pipeline, resp *http.Response) {
+				var u codecUser
+				if err := p.DecodeResponse(resp, &u); err != nil || u.Name != "clou" {
+					t.Errorf("decoded %+v, %v", u, err)
+				}
+			},
+		},
+		{
+			name:     "form",
+			response: chocotest.Response{Header: http.Header{HeaderContentType: {ContentTypeFormURLEncoded}}, Body: []byte(`a=1&b=2`)},
+			check: func(t *testing.T, p Pipeline, resp *http.Response) {
+				var v url.Values
+				if err := p.DecodeResponse(resp, &v); err != nil || v.Get("b") != "2" {
+					t.Errorf("decoded %v, %v", v, err)
+				}
+			},
+		},
+		{
+			name:     "no codec",
+			response: chocotest.Response{Header: http.Header{HeaderContentType: {"image/png"}}, Body: []byte{0x89}},
+			accept:   "image/*",
+			check: func(t *testing.T, p Pipeline, resp *http.Response) {
+				var b []byte
+				if err := p.DecodeResponse(resp, &b); err == nil {
+					t.Error("expected error for response without codec")
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := chocotest.NewTransport()
+			tr.On("GET", "").Respond(tt.response)
+			p, err := NewPipeline(WithCustomTransport(tr))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req, err := NewRequest(context.Background(), "GET", testURL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.accept != "" {
+				req.SetAccept(tt.accept)
+			}
+			resp, err := p.Execute(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.check(t, p, resp)
+		})
+	}
+}
+
+func TestCodecNegotiate(t *testing.T) {
+	r := DefaultCodecs()
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"application/xml;q=0.4, application/json", ContentTypeAppJSON},
+		{"text/*;q=0.8, application/json;q=0", ContentTypeTextPlain},
+		{"*/*", ContentTypeAppJSON},
+		{"image/png", ""},
+	}
+	for _, tt := range tests {
+		_, got, _ := r.Negotiate(tt.accept)
+		if got != tt.want {
+			t.Errorf("Negotiate(%q) = %q, want %q", tt.accept, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+// Package cookie provides a [choco.PipelineStep] keeping cookies across
+// requests, whatever the [choco.Transport], with optional persistence.
+//
+//	jar, err := cookie.NewJar(
+//	    cookie.WithPublicSuffixList(publicsuffix.List),
+//	    cookie.WithStore(cookie.NewFileStore("cookies.json")),
+//	)
+//	pipeline, err := choco.NewPipeline(choco.WithSteps(jar))
+//	...
+//	err = jar.Save()
+//
+// Cookies are matched with the semantics of [net/http/cookiejar]. The jar also
+// implements [http.CookieJar], so it can be set on an [http.Client] to keep
+// the cookies received during redirects.
+package cookie
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"nyxze/choco-go"
+)
+
+// Option configures a [Jar].
+type Option func(*Jar)
+
+// WithPublicSuffixList sets the public suffix list preventing servers from
+// setting cookies for whole domains such as "co.uk". Without it, as in
+// [net/http/cookiejar], only single-label domains are refused.
+// golang.org/x/net/publicsuffix provides a complete list.
+func WithPublicSuffixList(list cookiejar.PublicSuffixList) Option {
+	return func(j *Jar) {
+		j.psl = list
+	}
+}
+
+// WithStore sets the [Store] cookies are loaded from by [NewJar] and saved
+// to by [Jar.Save].
+func WithStore(store Store) Option {
+	return func(j *Jar) {
+		j.store = store
+	}
+}
+
+// Jar is a [choco.PipelineStep] adding stored cookies to requests and storing
+// the cookies set by responses. It is safe for concurrent use.
+type Jar struct {
+	psl   cookiejar.PublicSuffixList
+	store Store
+
+	jar *cookiejar.Jar
+
+	mu      sync.Mutex
+	entries map[string]Entry
+}
+
+// NewJar creates a [Jar], loading the cookies of its store if it has one.
+func NewJar(opts ...Option) (*Jar, error) {
+	j := &Jar{entries: map[string]Entry{}}
+	for _, opt := range opts {
+		opt(j)
+	}
+	jar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: j.psl})
+	if err != nil {
+		return nil, err
+	}
+	j.jar = jar
+	if j.store != nil {
+		if err := j.Load(); err != nil {
+			return nil, err
+		}
+	}
+	return j, nil
+}
+
+// Name implements [choco.NamedStep].
+func (j *Jar) Name() string {
+	return "cookie"
+}
+
+// Do implements [choco.PipelineStep].
+// Cookies already set on the request are kept over stored ones of the same name.
+func (j *Jar) Do(req *choco.Request, next choco.RequestHandlerFunc) (*http.Response, error) {
+	raw := req.Raw()
+	for _, c := range j.jar.Cookies(raw.URL) {
+		if _, err := raw.Cookie(c.Name); err == http.ErrNoCookie {
+			raw.AddCookie(c)
+		}
+	}
+
+	resp, err := next(req)
+	if resp != nil {
+		u := raw.URL
+		if resp.Request != nil && resp.Request.URL != nil {
+			u = resp.Request.URL
+		}
+		if cookies := resp.Cookies(); len(cookies) > 0 {
+			j.SetCookies(u, cookies)
+		}
+	}
+	return resp, err
+}
+
+// Cookies implements [http.CookieJar].
+func (j *Jar) Cookies(u *url.URL) []*http.Cookie {
+	return j.jar.Cookies(u)
+}
+
+// SetCookies implements [http.CookieJar].
+func (j *Jar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.jar.SetCookies(u, cookies)
+
+	now := time.Now()
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, c := range cookies {
+		e := newEntry(u, c, now)
+		key := e.key(u)
+		if c.MaxAge < 0 || (!e.Expires.IsZero() && !e.Expires.After(now)) {
+			delete(j.entries, key)
+			continue
+		}
+		if j.accepted(u, e) {
+			j.entries[key] = e
+		}
+	}
+}
+
+// accepted reports whether the cookie of e, set by u, was kept by the
+// underlying jar rather than refused, e.g. for a public suffix or another domain.
+func (j *Jar) accepted(u *url.URL, e Entry) bool {
+	check := &url.URL{Scheme: u.Scheme, Host: u.Host, Path: e.Path}
+	if e.Path == "" || e.Path[0] != '/' {
+		check.Path = defaultPath(u.Path)
+	}
+	if e.Secure {
+		check.Scheme = "https"
+	}
+	return slices.ContainsFunc(j.jar.Cookies(check), func(c *http.Cookie) bool {
+		return c.Name == e.Name && c.Value == e.Value
+	})
+}
+
+// Entries returns the cookies of the jar that have not expired, including
+// session cookies, in a stable order.
+func (j *Jar) Entries() []Entry {
+	now := time.Now()
+	j.mu.Lock()
+	entries := make([]Entry, 0, len(j.entries))
+	for _, e := range j.entries {
+		if e.Expires.IsZero() || e.Expires.After(now) {
+			entries = append(entries, e)
+		}
+	}
+	j.mu.Unlock()
+
+	slices.SortFunc(entries, func(a, b Entry) int {
+		return strings.Compare(a.URL+"\x00"+a.Name, b.URL+"\x00"+b.Name)
+	})
+	return entries
+}
+
+// Save writes the cookies of the jar to its store.
+func (j *Jar) Save() error {
+	if j.store == nil {
+		return choco.NewError("cookie: jar has no store")
+	}
+	return j.store.Save(j.Entries())
+}
+
+// Load adds the cookies of the store to the jar. Expired cookies are dropped.
+func (j *Jar) Load() error {
+	if j.store == nil {
+		return choco.NewError("cookie: jar has no store")
+	}
+	entries, err := j.store.Load()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, e := range entries {
+		if !e.Expires.IsZero() && !e.Expires.After(now) {
+			continue
+		}
+		u, err := url.Parse(e.URL)
+		if err != nil {
+			return choco.NewError("cookie: invalid URL %q in store: %v", e.URL, err)
+		}
+		j.SetCookies(u, []*http.Cookie{e.cookie()})
+	}
+	return nil
+}
+
+// Entry is a cookie as saved by a [Store], along with the URL that set it.
+type Entry struct {
+	URL      string        `json:"url"`
+	Name     string        `json:"name"`
+	Value    string        `json:"value"`
+	Domain   string        `json:"domain,omitempty"`
+	Path     string        `json:"path,omitempty"`
+	Expires  time.Time     `json:"expires,omitzero"`
+	Secure   bool          `json:"secure,omitempty"`
+	HttpOnly bool          `json:"httpOnly,omitempty"`
+	SameSite http.SameSite `json:"sameSite,omitempty"`
+}
+
+// newEntry records c as set by u at now. A Max-Age is turned into an
+// absolute expiry, so that it stays valid once saved.
+func newEntry(u *url.URL, c *http.Cookie, now time.Time) Entry {
+	e := Entry{
+		URL:      (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: u.Path}).String(),
+		Name:     c.Name,
+		Value:    c.Value,
+		Domain:   c.Domain,
+		Path:     c.Path,
+		Expires:  c.Expires,
+		Secure:   c.Secure,
+		HttpOnly: c.HttpOnly,
+		SameSite: c.SameSite,
+	}
+	if c.MaxAge > 0 {
+		e.Expires = now.Add(time.Duration(c.MaxAge) * time.Second)
+	}
+	if !e.Expires.IsZero() {
+		e.Expires = e.Expires.UTC().Truncate(time.Second)
+	}
+	return e
+}
+
+func (e Entry) cookie() *http.Cookie {
+	return &http.Cookie{
+		Name:     e.Name,
+		Value:    e.Value,
+		Domain:   e.Domain,
+		Path:     e.Path,
+		Expires:  e.Expires,
+		Secure:   e.Secure,
+		HttpOnly: e.HttpOnly,
+		SameSite: e.SameSite,
+	}
+}
+
+// key identifies the cookie the way a cookie jar does: by domain, path and name.
+func (e Entry) key(u *url.URL) string {
+	domain := strings.ToLower(strings.TrimPrefix(e.Domain, "."))
+	if domain == "" {
+		domain = strings.ToLower(u.Hostname())
+	}
+	p := e.Path
+	if p == "" || p[0] != '/' {
+		p = defaultPath(u.Path)
+	}
+	return domain + ";" + p + ";" + e.Name
+}
+
+// defaultPath is the default cookie path of RFC 6265 section 5.1.4.
+func defaultPath(urlPath string) string {
+	i := strings.LastIndexByte(urlPath, '/')
+	if urlPath == "" || urlPath[0] != '/' || i == 0 {
+		return "/"
+	}
+	return urlPath[:i]
+}
@@ -0,0 +1,142 @@
+package cookie_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"nyxze/choco-go"
+	"nyxze/choco-go/chocotest"
+	"nyxze/choco-go/cookie"
+)
+
+func cookieTransport() *chocotest.Transport {
+	tr := chocotest.NewTransport()
+	tr.On("POST", "/login").Respond(chocotest.Response{
+		StatusCode: http.StatusOK,
+		Header: http.Header{"Set-Cookie": {
+			"session=abc; Path=/",
+			"remember=yes; Max-Age=3600; Domain=example.com",
+			"tracking=1; Domain=example.com; Max-Age=3600",
+		}},
+	})
+	tr.On("POST", "/logout").Respond(chocotest.Status(http.StatusOK).
+		WithHeader("Set-Cookie", "session=; Max-Age=0; Path=/"))
+	tr.On("*", "").Respond(chocotest.Status(http.StatusOK))
+	return tr
+}
+
+func execute(t *testing.T, tr *chocotest.Transport, jar *cookie.Jar, method, url string) string {
+	t.Helper()
+	p, err := choco.NewPipeline(choco.WithCustomTransport(tr), choco.WithSteps(jar))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := choco.NewRequest(context.Background(), method, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Execute(req); err != nil {
+		t.Fatal(err)
+	}
+	calls := tr.Calls()
+	var names []string
+	for _, c := range calls[len(calls)-1].Request.Cookies() {
+		names = append(names, c.Name+"="+c.Value)
+	}
+	slices.Sort(names)
+	return strings.Join(names, "; ")
+}
+
+func TestJarPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cookies.json")
+	tr := cookieTransport()
+	jar, err := cookie.NewJar(cookie.WithStore(cookie.NewFileStore(path)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	execute(t, tr, jar, "POST", "http://www.example.com/login")
+	if got := execute(t, tr, jar, "GET", "http://www.example.com/account"); got != "remember=yes; session=abc; tracking=1" {
+		t.Errorf("cookies sent = %q", got)
+	}
+	if got := execute(t, tr, jar, "GET", "http://api.example.com/"); got != "remember=yes; tracking=1" {
+		t.Errorf("cookies sent to another host = %q", got)
+	}
+	if err := jar.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != 0o600 {
+		t.Errorf("cookie file = %v, %v; want mode 0600", fi, err)
+	}
+
+	// Session cookies survive a restart.
+	restored, err := cookie.NewJar(cookie.WithStore(cookie.NewFileStore(path)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := execute(t, tr, restored, "GET", "http://www.example.com/account"); got != "remember=yes; session=abc; tracking=1" {
+		t.Errorf("cookies sent after reload = %q", got)
+	}
+
+	// Deleted cookies are removed from the store, and request cookies win.
+	execute(t, tr, restored, "POST", "http://www.example.com/logout")
+	if len(restored.Entries()) != 2 {
+		t.Errorf("Entries() = %+v, want 2 cookies", restored.Entries())
+	}
+	p, _ := choco.NewPipeline(choco.WithCustomTransport(tr), choco.WithSteps(restored))
+	req, _ := choco.NewRequest(context.Background(), "GET", "http://www.example.com/")
+	req.Raw().AddCookie(&http.Cookie{Name: "tracking", Value: "0"})
+	if _, err := p.Execute(req); err != nil {
+		t.Fatal(err)
+	}
+	if c := tr.Calls()[len(tr.Calls())-1].Request.Header.Get("Cookie"); c != "tracking=0; remember=yes" {
+		t.Errorf("Cookie = %q", c)
+	}
+}
+
+// suffixList treats example.com as a public suffix.
+type suffixList struct{}
+
+func (suffixList) PublicSuffix(domain string) string {
+	if strings.HasSuffix(domain, "example.com") {
+		return "example.com"
+	}
+	i := strings.LastIndexByte(domain, '.')
+	return domain[i+1:]
+}
+
+func (suffixList) String() string { return "test" }
+
+func TestJarPublicSuffixList(t *testing.T) {
+	tr := cookieTransport()
+	jar, err := cookie.NewJar(cookie.WithPublicSuffixList(suffixList{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	execute(t, tr, jar, "POST", "http://www.example.com/login")
+	if got := execute(t, tr, jar, "GET", "http://www.example.com/"); got != "session=abc" {
+		t.Errorf("cookies sent = %q, want domain cookies refused", got)
+	}
+	if entries := jar.Entries(); len(entries) != 1 || entries[0].Name != "session" {
+		t.Errorf("Entries() = %+v, want only the session cookie", entries)
+	}
+
+	// Cookies for another domain are refused, and never listed.
+	other, err := url.Parse("http://www.example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	jar.SetCookies(other, []*http.Cookie{{Name: "foreign", Value: "1", Domain: "other.org"}})
+	if entries := jar.Entries(); len(entries) != 1 {
+		t.Errorf("Entries() = %+v, want the foreign cookie refused", entries)
+	}
+	if err := jar.Save(); err == nil {
+		t.Error("expected error saving a jar without store")
+	}
+}
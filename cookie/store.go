@@ -0,0 +1,71 @@
+package cookie
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"nyxze/choco-go"
+)
+
+// Store persists the cookies of a [Jar].
+type Store interface {
+	// Load returns the saved cookies, or none if nothing was saved yet.
+	Load() ([]Entry, error)
+	// Save replaces the saved cookies with entries.
+	Save(entries []Entry) error
+}
+
+// FileStore is a [Store] keeping cookies in a JSON file.
+type FileStore struct {
+	path string
+}
+
+// NewFileStore returns a [FileStore] for the file at path. The file is
+// created by the first save.
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path}
+}
+
+// Load implements [Store]. A missing file holds no cookies.
+func (s *FileStore) Load() ([]Entry, error) {
+	b, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []Entry
+	if err := json.Unmarshal(b, &entries); err != nil {
+		return nil, choco.NewError("cookie: invalid cookie file %s: %v", s.path, err)
+	}
+	return entries, nil
+}
+
+// Save implements [Store]. The file is replaced atomically and is only
+// readable by its owner, as cookies often hold credentials.
+func (s *FileStore) Save(entries []Entry) error {
+	if entries == nil {
+		entries = []Entry{}
+	}
+	b, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
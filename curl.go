@@ -0,0 +1,553 @@
+package choco
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// CurlOption configures [Request.ToCurl].
+type CurlOption func(*curlConfig)
+
+type curlConfig struct {
+	bodyFile string
+	redact   map[string]struct{}
+}
+
+// WithCurlBodyFile writes the request body to the named file and references it
+// with --data-binary @name instead of inlining it in the command. Use it for
+// large or binary bodies.
+func WithCurlBodyFile(name string) CurlOption {
+	return func(c *curlConfig) {
+		c.bodyFile = name
+	}
+}
+
+// WithCurlRedaction replaces the values of Authorization, Proxy-Authorization,
+// Cookie and the given extra headers with REDACTED, so that the command can
+// be shared safely.
+func WithCurlRedaction(headers ...string) CurlOption {
+	return func(c *curlConfig) {
+		for _, h := range append([]string{HeaderAuthorization, "Proxy-Authorization", "Cookie"}, headers...) {
+			c.redact[http.CanonicalHeaderKey(h)] = struct{}{}
+		}
+	}
+}
+
+// ParseCurlOption configures [ParseCurl].
+type ParseCurlOption func(*parseCurlConfig)
+
+type parseCurlConfig struct {
+	filesDir string
+	stdin    io.Reader
+}
+
+// WithCurlFiles allows data arguments to read files (-d @name), resolved
+// relative to dir. Names escaping dir, through .. or symbolic links, are refused.
+func WithCurlFiles(dir string) ParseCurlOption {
+	return func(c *parseCurlConfig) {
+		c.filesDir = dir
+	}
+}
+
+// WithCurlStdin allows data arguments to read r in place of stdin (-d @-).
+func WithCurlStdin(r io.Reader) ParseCurlOption {
+	return func(c *parseCurlConfig) {
+		c.stdin = r
+	}
+}
+
+// ToCurl returns a curl command line reproducing the request, quoted for
+// POSIX shells.
+//
+// Example:
+//
+//	curl -X POST 'https://api.example.com/users' -H 'Content-Type: application/json' --data-binary '{"name":"clou"}'
+//
+// The body is inlined unless [WithCurlBodyFile] is given; bodies containing NUL
+// bytes cannot be inlined and return an error. A value set with
+// [Request.SetBodyAs] is encoded with the default codecs. Stream bodies (see
+// [Request.SetStreamBody]) cannot be read without consuming them and return
+// an error.
+func (r *Request) ToCurl(opts ...CurlOption) (string, error) {
+	if r.req == nil {
+		return "", NewError("request: missing inner *http.Request")
+	}
+	r, err := r.withEncodedBody()
+	if err != nil {
+		return "", err
+	}
+	cfg := curlConfig{redact: map[string]struct{}{}}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	if r.stream != nil {
+		return "", NewError("curl: stream body cannot be exported")
+	}
+	args := []string{"curl"}
+	body, err := r.bodyBytes()
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case r.req.Method == http.MethodHead:
+		// With -X HEAD, curl waits for a response body that never comes.
+		args = append(args, "-I")
+	case r.req.Method != http.MethodGet || body != nil:
+		if !(r.req.Method == http.MethodPost && body != nil) {
+			args = append(args, "-X", shellQuote(r.req.Method))
+		}
+	}
+	args = append(args, shellQuote(r.req.URL.String()))
+
+	keys := make([]string, 0, len(r.req.Header))
+	for k := range r.req.Header {
+		if k != HeaderContentLength {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		for _, v := range r.req.Header[k] {
+			if _, ok := cfg.redact[k]; ok {
+				v = "REDACTED"
+			}
+			args = append(args, "-H", shellQuote(k+": "+v))
+		}
+	}
+	if r.req.Host != "" && r.req.Host != r.req.URL.Host {
+		args = append(args, "-H", shellQuote("Host: "+r.req.Host))
+	}
+
+	if body != nil {
+		switch {
+		case cfg.bodyFile != "":
+			if err := os.WriteFile(cfg.bodyFile, body, 0o600); err != nil {
+				return "", err
+			}
+			args = append(args, "--data-binary", shellQuote("@"+cfg.bodyFile))
+		case bytes.IndexByte(body, 0) >= 0 || !utf8.Valid(body):
+			return "", NewError("curl: binary body cannot be inlined, use WithCurlBodyFile")
+		case len(body) > 0 && body[0] == '@':
+			// A leading @ would be read as a file name.
+			args = append(args, "--data-raw", shellQuote(string(body)))
+		default:
+			args = append(args, "--data-binary", shellQuote(string(body)))
+		}
+	}
+	return strings.Join(args, " "), nil
+}
+
+// bodyBytes returns the whole request body without consuming it.
+func (r *Request) bodyBytes() ([]byte, error) {
+	if r.body == nil {
+		return nil, nil
+	}
+	if _, err := r.body.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	b, err := io.ReadAll(r.body)
+	if err != nil {
+		return nil, err
+	}
+	_, err = r.body.Seek(0, io.SeekStart)
+	return b, err
+}
+
+// shellQuote quotes s for a POSIX shell, leaving simple words untouched.
+func shellQuote(s string) string {
+	if s == "" {
+		return "''"
+	}
+	safe := true
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !(isAlphaNum(c) || strings.IndexByte("_@%+=:,./-", c) >= 0) {
+			safe = false
+			break
+		}
+	}
+	if safe {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// ParseCurl builds a [Request] from a curl command line, e.g. one pasted from
+// a bug report or browser devtools.
+//
+// Supported flags are -X/--request, -H/--header, -d/--data, --data-raw,
+// --data-ascii, --data-binary, --data-urlencode, --json, -u/--user, -G/--get,
+// -I/--head, -A/--user-agent, -e/--referer, -b/--cookie and --url. Output and
+// connection flags such as -s, -v, -L, -k or --compressed are ignored. Any
+// other flag is an error.
+//
+// Data arguments referencing a file (@name) or stdin (@-) are refused unless
+// allowed with [WithCurlFiles] or [WithCurlStdin], so that parsing an
+// untrusted command never reads local files.
+func ParseCurl(ctx context.Context, cmd string, opts ...ParseCurlOption) (*Request, error) {
+	var cfg parseCurlConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	words, err := shellSplit(cmd)
+	if err != nil {
+		return nil, err
+	}
+	if len(words) > 0 && words[0] == "curl" {
+		words = words[1:]
+	}
+
+	var (
+		method   string
+		rawURL   string
+		header   = http.Header{}
+		data     [][]byte
+		jsonBody bool
+		get      bool
+		user     string
+	)
+
+	next := func(i *int, flag string) (string, error) {
+		*i++
+		if *i >= len(words) {
+			return "", NewError("curl: flag %s requires a value", flag)
+		}
+		return words[*i], nil
+	}
+
+	for i := 0; i < len(words); i++ {
+		w := words[i]
+		if !strings.HasPrefix(w, "-") || w == "-" {
+			if rawURL != "" {
+				return nil, NewError("curl: unexpected argument %q", w)
+			}
+			rawURL = w
+			continue
+		}
+
+		flag, value, attached := w, "", false
+		if !strings.HasPrefix(w, "--") && len(w) > 2 {
+			// Short flags may be combined (-sSL) or carry their value (-XPOST).
+			for j := 1; j < len(w); j++ {
+				f := "-" + string(w[j])
+				if curlValueFlags[f] {
+					flag, value, attached = f, w[j+1:], j+1 < len(w)
+					break
+				}
+				if !curlIgnoredFlags[f] && !curlBoolFlags[f] {
+					return nil, NewError("curl: unsupported flag %s", f)
+				}
+				flag = f
+				if f == "-G" {
+					get = true
+				} else if f == "-I" {
+					method = http.MethodHead
+				}
+			}
+			if !curlValueFlags[flag] {
+				continue
+			}
+		}
+		if curlValueFlags[flag] && !attached {
+			if value, err = next(&i, flag); err != nil {
+				return nil, err
+			}
+		}
+
+		switch flag {
+		case "-X", "--request":
+			method = strings.ToUpper(value)
+		case "-H", "--header":
+			k, v, ok := strings.Cut(value, ":")
+			if !ok {
+				return nil, NewError("curl: invalid header %q", value)
+			}
+			header.Add(strings.TrimSpace(k), strings.TrimSpace(v))
+		case "-d", "--data", "--data-ascii":
+			b, err := cfg.data(value, true)
+			if err != nil {
+				return nil, err
+			}
+			data = append(data, b)
+		case "--data-binary":
+			b, err := cfg.data(value, false)
+			if err != nil {
+				return nil, err
+			}
+			data = append(data, b)
+		case "--data-raw":
+			data = append(data, []byte(value))
+		case "--data-urlencode":
+			data = append(data, []byte(curlURLEncode(value)))
+		case "--json":
+			b, err := cfg.data(value, false)
+			if err != nil {
+				return nil, err
+			}
+			data = append(data, b)
+			jsonBody = true
+		case "-u", "--user":
+			user = value
+		case "-A", "--user-agent":
+			header.Set(HeaderUserAgent, value)
+		case "-e", "--referer":
+			header.Set("Referer", value)
+		case "-b", "--cookie":
+			header.Add("Cookie", value)
+		case "--url":
+			rawURL = value
+		case "-G", "--get":
+			get = true
+		case "-I", "--head":
+			method = http.MethodHead
+		default:
+			if !curlIgnoredFlags[flag] {
+				return nil, NewError("curl: unsupported flag %s", flag)
+			}
+		}
+	}
+
+	if rawURL == "" {
+		return nil, NewError("curl: missing URL")
+	}
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "http://" + rawURL
+	}
+
+	body := bytes.Join(data, []byte("&"))
+	if get && len(data) > 0 {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return nil, err
+		}
+		if u.RawQuery != "" {
+			u.RawQuery += "&"
+		}
+		u.RawQuery += string(body)
+		rawURL = u.String()
+		data = nil
+	}
+	if jsonBody {
+		body = bytes.Join(data, nil)
+	}
+	if method == "" {
+		method = http.MethodGet
+		if len(data) > 0 {
+			method = http.MethodPost
+		}
+	}
+
+	req, err := NewRequest(ctx, method, rawURL)
+	if err != nil {
+		return nil, err
+	}
+	for k, vs := range header {
+		req.req.Header[k] = vs
+	}
+	if user != "" {
+		name, pass, _ := strings.Cut(user, ":")
+		req.SetBasicAuth(name, pass)
+	}
+	if len(data) > 0 {
+		contentType := header.Get(HeaderContentType)
+		switch {
+		case contentType != "":
+		case jsonBody:
+			contentType = ContentTypeAppJSON
+		default:
+			contentType = ContentTypeFormURLEncoded
+		}
+		if err := req.SetBody(NopCloser(bytes.NewReader(body)), contentType); err != nil {
+			return nil, err
+		}
+	}
+	if jsonBody && header.Get(HeaderAccept) == "" {
+		req.SetAccept(ContentTypeAppJSON)
+	}
+	return req, nil
+}
+
+var curlValueFlags = map[string]bool{
+	"-X": true, "--request": true,
+	"-H": true, "--header": true,
+	"-d": true, "--data": true, "--data-ascii": true, "--data-binary": true,
+	"--data-raw": true, "--data-urlencode": true, "--json": true,
+	"-u": true, "--user": true,
+	"-A": true, "--user-agent": true,
+	"-e": true, "--referer": true,
+	"-b": true, "--cookie": true,
+	"--url": true,
+}
+
+var curlBoolFlags = map[string]bool{
+	"-G": true, "--get": true,
+	"-I": true, "--head": true,
+}
+
+var curlIgnoredFlags = map[string]bool{
+	"-s": true, "--silent": true,
+	"-S": true, "--show-error": true,
+	"-v": true, "--verbose": true,
+	"-i": true, "--include": true,
+	"-L": true, "--location": true,
+	"-k": true, "--insecure": true,
+	"-f": true, "--fail": true,
+	"--compressed": true,
+}
+
+// curlData resolves a curl data argument, reading @file references.
+// Like curl's -d, stripNewlines removes CR and LF from file contents.
+func (c parseCurlConfig) data(value string, stripNewlines bool) ([]byte, error) {
+	name, ok := strings.CutPrefix(value, "@")
+	if !ok {
+		return []byte(value), nil
+	}
+	var (
+		b   []byte
+		err error
+	)
+	switch {
+	case name == "-" && c.stdin == nil:
+		return nil, NewError("curl: reading data from stdin is not allowed (see WithCurlStdin)")
+	case name == "-":
+		b, err = io.ReadAll(c.stdin)
+	case c.filesDir == "":
+		return nil, NewError("curl: reading data from file %q is not allowed (see WithCurlFiles)", name)
+	default:
+		b, err = readFileIn(c.filesDir, name)
+	}
+	if err != nil {
+		return nil, NewError("curl: reading data from %q: %v", value, err)
+	}
+	if stripNewlines {
+		b = bytes.ReplaceAll(bytes.ReplaceAll(b, []byte("\r"), nil), []byte("\n"), nil)
+	}
+	return b, nil
+}
+
+// readFileIn reads the named file relative to dir, refusing paths escaping it.
+func readFileIn(dir, name string) ([]byte, error) {
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer root.Close()
+	f, err := root.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// curlURLEncode implements the "content" and "name=content" forms of --data-urlencode.
+func curlURLEncode(value string) string {
+	name, content, ok := strings.Cut(value, "=")
+	if !ok {
+		return url.QueryEscape(value)
+	}
+	if name == "" {
+		return url.QueryEscape(content)
+	}
+	return name + "=" + url.QueryEscape(content)
+}
+
+// shellSplit splits a command line into words using POSIX shell quoting
+// rules: single quotes, double quotes, backslash escapes, $'...' strings and
+// backslash-newline continuations.
+func shellSplit(s string) ([]string, error) {
+	var (
+		words  []string
+		word   strings.Builder
+		inWord bool
+		flush  = func() {
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		}
+	)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			flush()
+		case c == '\\':
+			if i+1 < len(s) {
+				i++
+				if s[i] != '\n' {
+					word.WriteByte(s[i])
+					inWord = true
+				}
+			}
+		case c == '\'':
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				return nil, NewError("curl: unterminated single quote")
+			}
+			word.WriteString(s[i+1 : i+1+end])
+			inWord = true
+			i += end + 1
+		case c == '$' && i+1 < len(s) && s[i+1] == '\'':
+			j := i + 2
+			for ; j < len(s) && s[j] != '\''; j++ {
+				if s[j] == '\\' && j+1 < len(s) {
+					j++
+					word.WriteString(ansiCEscape(s[j]))
+					continue
+				}
+				word.WriteByte(s[j])
+			}
+			if j >= len(s) {
+				return nil, NewError("curl: unterminated $' quote")
+			}
+			inWord = true
+			i = j
+		case c == '"':
+			j := i + 1
+			for ; j < len(s) && s[j] != '"'; j++ {
+				if s[j] == '\\' && j+1 < len(s) && strings.IndexByte("\"\\$`\n", s[j+1]) >= 0 {
+					j++
+					if s[j] == '\n' {
+						continue
+					}
+				}
+				word.WriteByte(s[j])
+			}
+			if j >= len(s) {
+				return nil, NewError("curl: unterminated double quote")
+			}
+			inWord = true
+			i = j
+		default:
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+	flush()
+	return words, nil
+}
+
+func ansiCEscape(c byte) string {
+	switch c {
+	case 'n':
+		return "\n"
+	case 't':
+		return "\t"
+	case 'r':
+		return "\r"
+	case '0':
+		return "\x00"
+	default:
+		return string(c)
+	}
+}
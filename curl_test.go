@@ -0,0 +1,265 @@
+package choco
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestToCurl(t *testing.T) {
+	ctx := context.Background()
+	req, err := NewRequest(ctx, "POST", "https://api.example.com/users?q=a%20b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetHeader("X-Note", "it's choco")
+	req.SetAuthorization(AuthSchemeBearer, "secret")
+	if err := req.SetBody(NopCloser(strings.NewReader(`{"name":"clou"}`)), ContentTypeAppJSON); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := req.ToCurl(WithCurlRedaction())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `curl 'https://api.example.com/users?q=a%20b' -H 'Authorization: REDACTED' -H 'Content-Type: application/json' -H 'X-Note: it'\''s choco' --data-binary '{"name":"clou"}'`
+	if got != want {
+		t.Errorf("ToCurl() =\n%s\nwant\n%s", got, want)
+	}
+
+	// The body must still be readable after the export.
+	if b, _ := io.ReadAll(req.Body()); string(b) != `{"name":"clou"}` {
+		t.Errorf("body consumed by ToCurl: %q", b)
+	}
+
+	file := filepath.Join(t.TempDir(), "body.bin")
+	got, err = req.ToCurl(WithCurlBodyFile(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(got, "--data-binary @"+file) {
+		t.Errorf("ToCurl() with body file = %s", got)
+	}
+	if b, _ := os.ReadFile(file); string(b) != `{"name":"clou"}` {
+		t.Errorf("body file = %q", b)
+	}
+}
+
+func TestExportBodyValue(t *testing.T) {
+	req, err := NewRequest(context.Background(), "POST", "https://api.example.com/users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBodyAs(map[string]string{"name": "clou"})
+
+	got, err := req.ToCurl()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `curl https://api.example.com/users -H 'Content-Type: application/json' --data-binary '{"name":"clou"}'`
+	if got != want {
+		t.Errorf("ToCurl() =\n%s\nwant\n%s", got, want)
+	}
+	dump, err := req.DumpRequest(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(string(dump), "\r\n\r\n"+`{"name":"clou"}`) || !strings.Contains(string(dump), "Content-Type: application/json") {
+		t.Errorf("DumpRequest() =\n%s", dump)
+	}
+	// The value is left for the pipeline to encode with its own codecs.
+	if req.bodyValue == nil || req.Body() != nil {
+		t.Error("exports modified the request")
+	}
+}
+
+func TestToCurlStreamBody(t *testing.T) {
+	req, err := NewRequest(context.Background(), "PUT", "https://api.example.com/upload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := req.SetStreamBody(strings.NewReader("data"), 4, ContentTypeTextPlain); err != nil {
+		t.Fatal(err)
+	}
+	if cmd, err := req.ToCurl(); err == nil || !strings.Contains(err.Error(), "stream body cannot be exported") {
+		t.Errorf("ToCurl() = %s, err = %v, want a stream body error", cmd, err)
+	}
+}
+
+func TestParseCurl(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name       string
+		cmd        string
+		method     string
+		url        string
+		body       string
+		headers    map[string]string
+		wantErrMsg string
+	}{
+		{
+			name:   "simple get",
+			cmd:    `curl -sSL https://api.example.com/users`,
+			method: "GET",
+			url:    "https://api.example.com/users",
+		},
+		{
+			name:    "post with headers and data",
+			cmd:     "curl -XPOST 'https://api.example.com/users' \\\n  -H 'X-Note: it'\\''s choco' -H \"Accept: text/plain\" -d 'a=1' -d b=2",
+			method:  "POST",
+			url:     "https://api.example.com/users",
+			body:    "a=1&b=2",
+			headers: map[string]string{"X-Note": "it's choco", "Accept": "text/plain", "Content-Type": "application/x-www-form-urlencoded"},
+		},
+		{
+			name:    "json and basic auth",
+			cmd:     `curl --json '{"name":"clou"}' -u clou:kweh https://api.example.com/users`,
+			method:  "POST",
+			url:     "https://api.example.com/users",
+			body:    `{"name":"clou"}`,
+			headers: map[string]string{"Content-Type": ContentTypeAppJSON, "Accept": ContentTypeAppJSON, "Authorization": "Basic Y2xvdTprd2Vo"},
+		},
+		{
+			name:   "get with data in query",
+			cmd:    `curl -G --data-urlencode 'q=choco bo' -d page=2 'https://api.example.com/search?x=1'`,
+			method: "GET",
+			url:    "https://api.example.com/search?x=1&q=choco+bo&page=2",
+		},
+		{
+			name:   "explicit method and data-binary",
+			cmd:    `curl -X put --data-binary $'line1\nline2' api.example.com/doc`,
+			method: "PUT",
+			url:    "http://api.example.com/doc",
+			body:   "line1\nline2",
+		},
+		{name: "unknown flag", cmd: `curl --proxy http://p https://x`, wantErrMsg: "unsupported flag --proxy"},
+		{name: "missing url", cmd: `curl -X GET`, wantErrMsg: "missing URL"},
+		{name: "bad quote", cmd: `curl 'https://x`, wantErrMsg: "unterminated"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := ParseCurl(ctx, tt.cmd)
+			if tt.wantErrMsg != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErrMsg) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErrMsg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			raw := req.Raw()
+			if raw.Method != tt.method || raw.URL.String() != tt.url {
+				t.Errorf("got %s %s, want %s %s", raw.Method, raw.URL, tt.method, tt.url)
+			}
+			var body string
+			if req.Body() != nil {
+				b, _ := io.ReadAll(req.Body())
+				body = string(b)
+			}
+			if body != tt.body {
+				t.Errorf("body = %q, want %q", body, tt.body)
+			}
+			for k, v := range tt.headers {
+				if got := raw.Header.Get(k); got != v {
+					t.Errorf("header %s = %q, want %q", k, got, v)
+				}
+			}
+		})
+	}
+}
+
+func TestCurlRoundTripHead(t *testing.T) {
+	ctx := context.Background()
+	req, err := NewRequest(ctx, "HEAD", "https://api.example.com/users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd, err := req.ToCurl()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmd != "curl -I https://api.example.com/users" {
+		t.Errorf("ToCurl() = %s", cmd)
+	}
+	for _, c := range []string{cmd, "curl --head https://api.example.com/users"} {
+		parsed, err := ParseCurl(ctx, c)
+		if err != nil {
+			t.Fatalf("ParseCurl(%s): %v", c, err)
+		}
+		if m := parsed.Raw().Method; m != http.MethodHead {
+			t.Errorf("ParseCurl(%s) method = %s, want HEAD", c, m)
+		}
+	}
+}
+
+func TestParseCurlDataFiles(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "body.txt"), []byte("a=1\nb=2\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cmd := `curl -d @body.txt https://x`
+
+	if _, err := ParseCurl(ctx, cmd); err == nil || !strings.Contains(err.Error(), "not allowed") {
+		t.Errorf("files should be refused by default, err = %v", err)
+	}
+	if _, err := ParseCurl(ctx, `curl -d @- https://x`); err == nil || !strings.Contains(err.Error(), "not allowed") {
+		t.Errorf("stdin should be refused by default, err = %v", err)
+	}
+
+	req, err := ParseCurl(ctx, cmd, WithCurlFiles(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := io.ReadAll(req.Body()); string(b) != "a=1b=2" {
+		t.Errorf("file body = %q", b)
+	}
+	req, err = ParseCurl(ctx, `curl --data-binary @- https://x`, WithCurlStdin(strings.NewReader("line\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := io.ReadAll(req.Body()); string(b) != "line\n" {
+		t.Errorf("stdin body = %q", b)
+	}
+
+	outside := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(outside, []byte("secret"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"../" + filepath.Base(filepath.Dir(outside)) + "/secret", outside} {
+		if _, err := ParseCurl(ctx, "curl -d @"+name+" https://x", WithCurlFiles(dir)); err == nil {
+			t.Errorf("reading %s outside of the files directory should fail", name)
+		}
+	}
+}
+
+func TestCurlRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	req, err := NewRequest(ctx, "PATCH", "https://api.example.com/users/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetHeader("X-Quote", `"double" and 'single' $HOME`)
+	if err := req.SetBody(NopCloser(strings.NewReader("@not-a-file")), ContentTypeTextPlain); err != nil {
+		t.Fatal(err)
+	}
+	cmd, err := req.ToCurl()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseCurl(ctx, cmd)
+	if err != nil {
+		t.Fatalf("ParseCurl(%s): %v", cmd, err)
+	}
+	if parsed.Raw().Method != "PATCH" || parsed.Raw().Header.Get("X-Quote") != req.Raw().Header.Get("X-Quote") {
+		t.Errorf("round trip mismatch: %s", cmd)
+	}
+	if b, _ := io.ReadAll(parsed.Body()); string(b) != "@not-a-file" {
+		t.Errorf("round trip body = %q", b)
+	}
+}
@@ -0,0 +1,324 @@
+// Package fault provides a [choco.PipelineStep] that injects faults (latency,
+// synthetic error statuses, transport errors, truncated or slow bodies) into a
+// pipeline, to test how callers behave under bad network conditions.
+//
+// Rules are built with one of the fault constructors and narrowed down with
+// the For* and With* methods:
+//
+//	injector := fault.NewInjector(fault.WithSeed(42),
+//	    fault.WithRules(
+//	        fault.Latency(200*time.Millisecond, 50*time.Millisecond).WithPercentage(25),
+//	        fault.Status(http.StatusTooManyRequests).WithRetryAfter(time.Second).ForHost("*.example.com"),
+//	        fault.TruncateBody(512).ForPathPrefix("/download"),
+//	    ),
+//	)
+//	pipeline, err := choco.NewPipeline(choco.WithSteps(injector))
+package fault
+
+import (
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"nyxze/choco-go"
+)
+
+// ErrInjected is the transport error returned by an [Error] rule created with a nil error.
+var ErrInjected = errors.New("fault: injected transport error")
+
+type kind int
+
+const (
+	kindLatency kind = iota
+	kindStatus
+	kindError
+	kindTruncate
+	kindSlowBody
+)
+
+// Rule describes a fault and the requests it applies to.
+// The zero value is not useful; use one of the constructors.
+type Rule struct {
+	kind kind
+
+	// Matching, with the predicates of the choco package
+	host        choco.RequestPredicate
+	pathPrefix  choco.RequestPredicate
+	methods     choco.RequestPredicate
+	probability float64
+
+	// Fault parameters
+	latency    time.Duration
+	jitter     time.Duration
+	status     int
+	retryAfter time.Duration
+	err        error
+	limit      int64
+	chunk      int
+}
+
+// Latency delays the request by d plus a random duration in [0, jitter).
+// The delay is cut short when the request context is done.
+func Latency(d, jitter time.Duration) Rule {
+	return Rule{kind: kindLatency, latency: d, jitter: jitter, probability: 1}
+}
+
+// Status answers the request with a synthetic response of the given status
+// code, without calling the next step.
+func Status(code int) Rule {
+	return Rule{kind: kindStatus, status: code, probability: 1}
+}
+
+// Error fails the request with err, without calling the next step.
+// A nil err is replaced by [ErrInjected].
+func Error(err error) Rule {
+	if err == nil {
+		err = ErrInjected
+	}
+	return Rule{kind: kindError, err: err, probability: 1}
+}
+
+// TruncateBody cuts the response body after n bytes; further reads
+// return [io.ErrUnexpectedEOF].
+func TruncateBody(n int64) Rule {
+	return Rule{kind: kindTruncate, limit: n, probability: 1}
+}
+
+// SlowBody makes every response body read return at most chunk bytes
+// after waiting for delay.
+func SlowBody(chunk int, delay time.Duration) Rule {
+	return Rule{kind: kindSlowBody, chunk: max(chunk, 1), latency: delay, probability: 1}
+}
+
+// ForHost restricts the rule to hosts matching pattern, as [choco.MatchHost]
+// does (e.g. "*.example.com").
+func (r Rule) ForHost(pattern string) Rule {
+	r.host = choco.MatchHost(pattern)
+	return r
+}
+
+// ForPathPrefix restricts the rule to URL paths starting with prefix, as
+// [choco.MatchPathPrefix] does.
+func (r Rule) ForPathPrefix(prefix string) Rule {
+	r.pathPrefix = choco.MatchPathPrefix(prefix)
+	return r
+}
+
+// ForMethod restricts the rule to the given HTTP methods.
+func (r Rule) ForMethod(methods ...string) Rule {
+	r.methods = choco.MatchMethod(methods...)
+	return r
+}
+
+// WithProbability sets the chance, between 0 and 1, that the rule fires on
+// a matching request. Rules fire on every matching request by default.
+func (r Rule) WithProbability(p float64) Rule {
+	r.probability = min(max(p, 0), 1)
+	return r
+}
+
+// WithPercentage is like [Rule.WithProbability] with a value between 0 and 100.
+func (r Rule) WithPercentage(pct float64) Rule {
+	return r.WithProbability(pct / 100)
+}
+
+// WithRetryAfter adds a Retry-After header to a [Status] rule's response.
+func (r Rule) WithRetryAfter(d time.Duration) Rule {
+	r.retryAfter = d
+	return r
+}
+
+func (r Rule) matches(req *choco.Request) bool {
+	for _, match := range []choco.RequestPredicate{r.methods, r.host, r.pathPrefix} {
+		if match != nil && !match(req) {
+			return false
+		}
+	}
+	return true
+}
+
+// Option configures an [Injector].
+type Option func(*Injector)
+
+// WithRules appends rules to the injector. Rules are evaluated in order.
+func WithRules(rules ...Rule) Option {
+	return func(in *Injector) {
+		in.rules = append(in.rules, rules...)
+	}
+}
+
+// WithSeed makes the injector's random decisions reproducible.
+func WithSeed(seed uint64) Option {
+	return func(in *Injector) {
+		in.rnd = rand.New(rand.NewPCG(seed, seed))
+	}
+}
+
+// Injector is a [choco.PipelineStep] applying fault [Rule]s to requests.
+//
+// Every matching rule that fires is applied: latencies add up, the first
+// [Status] or [Error] rule short-circuits the request, and body faults
+// wrap the response body. It is safe for concurrent use.
+type Injector struct {
+	rules []Rule
+
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+// NewInjector creates an [Injector]. Without [WithSeed], a random seed is used.
+func NewInjector(opts ...Option) *Injector {
+	in := &Injector{}
+	for _, opt := range opts {
+		opt(in)
+	}
+	if in.rnd == nil {
+		in.rnd = rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))
+	}
+	return in
+}
+
+// Name implements [choco.NamedStep].
+func (in *Injector) Name() string {
+	return "fault"
+}
+
+// Do implements [choco.PipelineStep].
+func (in *Injector) Do(req *choco.Request, next choco.RequestHandlerFunc) (*http.Response, error) {
+	raw := req.Raw()
+
+	var delay time.Duration
+	var short *Rule
+	var body []Rule
+	for _, r := range in.rules {
+		if !r.matches(req) || !in.fires(r.probability) {
+			continue
+		}
+		switch r.kind {
+		case kindLatency:
+			delay += r.latency + in.jitter(r.jitter)
+		case kindStatus, kindError:
+			if short == nil {
+				short = &r
+			}
+		case kindTruncate, kindSlowBody:
+			body = append(body, r)
+		}
+	}
+
+	if delay > 0 {
+		if err := sleep(raw, delay); err != nil {
+			return nil, err
+		}
+	}
+	if short != nil {
+		if short.kind == kindError {
+			return nil, short.err
+		}
+		return syntheticResponse(raw, short.status, short.retryAfter), nil
+	}
+
+	resp, err := next(req)
+	if err != nil || resp == nil || resp.Body == nil {
+		return resp, err
+	}
+	for _, r := range body {
+		switch r.kind {
+		case kindTruncate:
+			resp.Body = &truncatedBody{ReadCloser: resp.Body, remaining: r.limit}
+			resp.ContentLength = -1
+		case kindSlowBody:
+			resp.Body = &slowBody{ReadCloser: resp.Body, req: raw, chunk: r.chunk, delay: r.latency}
+		}
+	}
+	return resp, nil
+}
+
+func (in *Injector) fires(p float64) bool {
+	if p >= 1 {
+		return true
+	}
+	if p <= 0 {
+		return false
+	}
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	return in.rnd.Float64() < p
+}
+
+func (in *Injector) jitter(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	return time.Duration(in.rnd.Int64N(int64(max)))
+}
+
+func sleep(req *http.Request, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-req.Context().Done():
+		return req.Context().Err()
+	}
+}
+
+func syntheticResponse(req *http.Request, code int, retryAfter time.Duration) *http.Response {
+	header := http.Header{}
+	if retryAfter > 0 {
+		header.Set(choco.HeaderRetryAfter, strconv.Itoa(int((retryAfter+time.Second-1)/time.Second)))
+	}
+	return &http.Response{
+		Status:     strconv.Itoa(code) + " " + http.StatusText(code),
+		StatusCode: code,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     header,
+		Body:       http.NoBody,
+		Request:    req,
+	}
+}
+
+// truncatedBody ends a body early with [io.ErrUnexpectedEOF].
+type truncatedBody struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (b *truncatedBody) Read(p []byte) (int, error) {
+	if b.remaining <= 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	if int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	return n, err
+}
+
+// slowBody returns at most chunk bytes per read, each after delay.
+type slowBody struct {
+	io.ReadCloser
+	req   *http.Request
+	chunk int
+	delay time.Duration
+}
+
+func (b *slowBody) Read(p []byte) (int, error) {
+	if err := sleep(b.req, b.delay); err != nil {
+		return 0, err
+	}
+	if len(p) > b.chunk {
+		p = p[:b.chunk]
+	}
+	return b.ReadCloser.Read(p)
+}
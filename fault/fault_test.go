@@ -0,0 +1,115 @@
+package fault_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"nyxze/choco-go"
+	"nyxze/choco-go/chocotest"
+	"nyxze/choco-go/fault"
+)
+
+func execute(t *testing.T, injector *fault.Injector, ctx context.Context, url string) (*http.Response, error) {
+	t.Helper()
+	tr := chocotest.NewTransport()
+	tr.On("*", "").Respond(chocotest.Text(http.StatusOK, "0123456789"))
+	p, err := choco.NewPipeline(choco.WithCustomTransport(tr), choco.WithSteps(injector))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := choco.NewRequest(ctx, "GET", url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p.Execute(req)
+}
+
+func TestInjectorStatusAndError(t *testing.T) {
+	injector := fault.NewInjector(fault.WithRules(
+		fault.Status(http.StatusTooManyRequests).WithRetryAfter(1500*time.Millisecond).ForHost("*.example.com"),
+		fault.Error(nil).ForPathPrefix("/down"),
+	))
+	ctx := context.Background()
+
+	resp, err := execute(t, injector, ctx, "http://api.example.com/users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get(choco.HeaderRetryAfter) != "2" {
+		t.Errorf("got %d Retry-After=%q", resp.StatusCode, resp.Header.Get(choco.HeaderRetryAfter))
+	}
+
+	if _, err := execute(t, injector, ctx, "http://other.org/down/now"); !errors.Is(err, fault.ErrInjected) {
+		t.Errorf("err = %v, want ErrInjected", err)
+	}
+
+	// Rules match like the route steps: case-insensitive hosts with any port,
+	// and whole path segments.
+	resp, err = execute(t, injector, ctx, "http://API.Example.com:8443/users")
+	if err != nil || resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("host with port got %v, %v", resp, err)
+	}
+	for _, url := range []string{"http://other.org/up", "http://other.org/downloads"} {
+		resp, err = execute(t, injector, ctx, url)
+		if err != nil || resp.StatusCode != http.StatusOK {
+			t.Errorf("unmatched request %s got %v, %v", url, resp, err)
+		}
+	}
+}
+
+func TestInjectorBodyFaults(t *testing.T) {
+	injector := fault.NewInjector(fault.WithRules(
+		fault.TruncateBody(4),
+		fault.SlowBody(2, time.Millisecond),
+	))
+	resp, err := execute(t, injector, context.Background(), "http://x/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(resp.Body)
+	if !errors.Is(err, io.ErrUnexpectedEOF) || string(b) != "0123" {
+		t.Errorf("read %q, %v; want truncated body", b, err)
+	}
+}
+
+func TestInjectorLatency(t *testing.T) {
+	injector := fault.NewInjector(fault.WithRules(fault.Latency(time.Second, 0)))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := execute(t, injector, ctx, "http://x/"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want deadline exceeded", err)
+	}
+}
+
+func TestInjectorSeededProbability(t *testing.T) {
+	run := func() string {
+		injector := fault.NewInjector(fault.WithSeed(7), fault.WithRules(
+			fault.Status(http.StatusServiceUnavailable).WithPercentage(50),
+		))
+		var sb strings.Builder
+		for range 32 {
+			resp, err := execute(t, injector, context.Background(), "http://x/")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode == http.StatusServiceUnavailable {
+				sb.WriteByte('F')
+			} else {
+				sb.WriteByte('.')
+			}
+		}
+		return sb.String()
+	}
+	first, second := run(), run()
+	if first != second {
+		t.Errorf("seeded runs differ:\n%s\n%s", first, second)
+	}
+	if !strings.Contains(first, "F") || !strings.Contains(first, ".") {
+		t.Errorf("50%% rule should fire sometimes: %s", first)
+	}
+}
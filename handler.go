@@ -0,0 +1,174 @@
+package choco
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// HandlerTransport returns a [Transport] serving requests in-process with h,
+// without opening any socket. It is meant for tests and embedded services.
+//
+// The handler receives a server-side request, as built by [net/http]: with
+// RequestURI, Host and a RemoteAddr set, and TLS connection state for https
+// URLs. The request body is streamed to the handler.
+//
+// The response is returned as soon as the handler writes its headers (with
+// WriteHeader, Write or Flush), and its body is streamed through an [io.Pipe],
+// so long-lived responses such as server-sent events are read while the
+// handler runs. Closing the response body cancels the handler context.
+//
+// Example:
+//
+//	mux := http.NewServeMux()
+//	mux.HandleFunc("GET /users/{id}", getUser)
+//	pipeline, err := NewPipeline(WithCustomTransport(HandlerTransport(mux)))
+func HandlerTransport(h http.Handler) Transport {
+	return handlerTransport{handler: h}
+}
+
+type handlerTransport struct {
+	handler http.Handler
+}
+
+// Send implements [Transport].
+func (t handlerTransport) Send(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithCancel(req.Context())
+	pr, pw := io.Pipe()
+	w := &pipeResponseWriter{header: http.Header{}, pw: pw, ready: make(chan struct{})}
+
+	go func() {
+		defer cancel()
+		defer func() {
+			if req.Body != nil {
+				req.Body.Close()
+			}
+			if v := recover(); v != nil {
+				w.finish(fmt.Errorf("handler panic: %v", v))
+				return
+			}
+			w.finish(nil)
+		}()
+		t.handler.ServeHTTP(w, serverRequest(ctx, req))
+	}()
+
+	select {
+	case <-w.ready:
+	case <-req.Context().Done():
+		err := req.Context().Err()
+		pr.CloseWithError(err)
+		return nil, err
+	}
+	if w.err != nil {
+		return nil, w.err
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", w.status, http.StatusText(w.status)),
+		StatusCode:    w.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        w.sent,
+		Body:          &handlerBody{PipeReader: pr, cancel: cancel},
+		ContentLength: w.contentLength,
+		Request:       req,
+	}, nil
+}
+
+// serverRequest builds the request seen by a handler for req.
+func serverRequest(ctx context.Context, req *http.Request) *http.Request {
+	sreq := req.Clone(ctx)
+	sreq.RequestURI = req.URL.RequestURI()
+	sreq.URL.Scheme = ""
+	sreq.URL.Host = ""
+	sreq.URL.User = nil
+	if sreq.Host == "" {
+		sreq.Host = req.URL.Host
+	}
+	sreq.Proto, sreq.ProtoMajor, sreq.ProtoMinor = "HTTP/1.1", 1, 1
+	sreq.RemoteAddr = "192.0.2.1:1234"
+	if req.URL.Scheme == "https" {
+		sreq.TLS = &tls.ConnectionState{
+			Version:           tls.VersionTLS13,
+			HandshakeComplete: true,
+			ServerName:        req.URL.Hostname(),
+		}
+	}
+	if sreq.Body == nil {
+		sreq.Body = http.NoBody
+	}
+	return sreq
+}
+
+// pipeResponseWriter is an [http.ResponseWriter] writing the body to a pipe.
+type pipeResponseWriter struct {
+	header http.Header
+	pw     *io.PipeWriter
+
+	once          sync.Once
+	ready         chan struct{}
+	status        int
+	sent          http.Header
+	contentLength int64
+	err           error // Set when the handler failed before writing headers
+}
+
+func (w *pipeResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *pipeResponseWriter) WriteHeader(status int) {
+	w.once.Do(func() {
+		w.status = status
+		w.sent = w.header.Clone()
+		w.contentLength = -1
+		if cl, err := strconv.ParseInt(w.sent.Get(HeaderContentLength), 10, 64); err == nil {
+			w.contentLength = cl
+		}
+		close(w.ready)
+	})
+}
+
+func (w *pipeResponseWriter) Write(p []byte) (int, error) {
+	if w.status == 0 && w.header.Get(HeaderContentType) == "" && len(p) > 0 {
+		w.header.Set(HeaderContentType, http.DetectContentType(p))
+	}
+	w.WriteHeader(http.StatusOK)
+	return w.pw.Write(p)
+}
+
+// Flush implements [http.Flusher]. Writes are unbuffered, so it only sends the headers.
+func (w *pipeResponseWriter) Flush() {
+	w.WriteHeader(http.StatusOK)
+}
+
+// finish ends the response once the handler returned, with err if it panicked.
+func (w *pipeResponseWriter) finish(err error) {
+	w.once.Do(func() {
+		w.err = err
+		w.status = http.StatusOK
+		w.sent = w.header.Clone()
+		w.contentLength = 0
+		close(w.ready)
+	})
+	if err != nil {
+		w.pw.CloseWithError(err)
+		return
+	}
+	w.pw.Close()
+}
+
+// handlerBody cancels the handler when the response body is closed.
+type handlerBody struct {
+	*io.PipeReader
+	cancel context.CancelFunc
+}
+
+func (b *handlerBody) Close() error {
+	b.cancel()
+	return b.PipeReader.Close()
+}
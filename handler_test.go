@@ -0,0 +1,115 @@
+package choco
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandlerTransport(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /echo/{id}", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set(HeaderContentLength, fmt.Sprint(len(body)))
+		w.WriteHeader(http.StatusAccepted)
+		w.Write(body)
+	})
+	mux.HandleFunc("GET /info", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s %s %s tls=%v", r.RequestURI, r.Host, r.RemoteAddr, r.TLS != nil && r.TLS.ServerName == "api.example.com")
+	})
+	mux.HandleFunc("GET /empty", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("GET /panic", func(w http.ResponseWriter, r *http.Request) { panic("boom") })
+
+	p, err := NewPipeline(WithCustomTransport(HandlerTransport(mux)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	do := func(method, url, body string) (*http.Response, string, error) {
+		t.Helper()
+		req, err := NewRequest(context.Background(), method, url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if body != "" {
+			if err := req.SetBody(NopCloser(strings.NewReader(body)), ContentTypeTextPlain); err != nil {
+				t.Fatal(err)
+			}
+		}
+		resp, err := p.Execute(req)
+		if err != nil {
+			return nil, "", err
+		}
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		return resp, string(b), err
+	}
+
+	resp, body, err := do("POST", "http://www.example.com/echo/1", "hello")
+	if err != nil || resp.StatusCode != http.StatusAccepted || body != "hello" || resp.ContentLength != 5 {
+		t.Errorf("echo: %v %q %v", resp, body, err)
+	}
+
+	resp, body, err = do("GET", "https://api.example.com/info?q=1", "")
+	if err != nil || body != "/info?q=1 api.example.com 192.0.2.1:1234 tls=true" {
+		t.Errorf("info: %q %v", body, err)
+	}
+	if ct := resp.Header.Get(HeaderContentType); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("sniffed Content-Type = %q", ct)
+	}
+
+	resp, body, err = do("GET", "http://www.example.com/empty", "")
+	if err != nil || resp.StatusCode != http.StatusOK || body != "" {
+		t.Errorf("empty: %v %q %v", resp, body, err)
+	}
+	resp, _, err = do("GET", "http://www.example.com/missing", "")
+	if err != nil || resp.StatusCode != http.StatusNotFound {
+		t.Errorf("missing: %v %v", resp, err)
+	}
+	if _, _, err := do("GET", "http://www.example.com/panic", ""); err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("panic: err = %v", err)
+	}
+}
+
+func TestHandlerTransportStreaming(t *testing.T) {
+	handlerDone := make(chan error, 1)
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(HeaderContentType, "text/event-stream")
+		w.(http.Flusher).Flush()
+		for i := 0; ; i++ {
+			if _, err := fmt.Fprintf(w, "data: %d\n\n", i); err != nil {
+				handlerDone <- r.Context().Err()
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	})
+	p, err := NewPipeline(WithCustomTransport(HandlerTransport(h)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := NewRequest(context.Background(), "GET", "http://www.example.com/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := p.Execute(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, len("data: 0\n\n"))
+	if _, err := io.ReadFull(resp.Body, buf); err != nil || string(buf) != "data: 0\n\n" {
+		t.Fatalf("first event = %q, %v", buf, err)
+	}
+	resp.Body.Close()
+	select {
+	case err := <-handlerDone:
+		if err != context.Canceled {
+			t.Errorf("handler context error = %v, want context.Canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("handler still running after the body was closed")
+	}
+}
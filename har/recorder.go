@@ -0,0 +1,455 @@
+package har
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"nyxze/choco-go"
+)
+
+// Redacted replaces the values removed by redaction rules.
+const Redacted = "REDACTED"
+
+// DefaultMaxBodySize is the number of body bytes kept per request and response
+// when no [WithMaxBodySize] option is given.
+const DefaultMaxBodySize = 1 << 20
+
+// Option configures a [Recorder].
+type Option func(*Recorder)
+
+// WithCreator sets the creator recorded in the log. Defaults to "choco-go".
+func WithCreator(name, version string) Option {
+	return func(r *Recorder) {
+		r.creator = Creator{Name: name, Version: version}
+	}
+}
+
+// WithMaxBodySize caps the number of request and response body bytes kept
+// per entry. Bodies are still sent and received in full; only the capture is
+// truncated. A negative size disables body capture.
+func WithMaxBodySize(n int64) Option {
+	return func(r *Recorder) {
+		r.maxBodySize = n
+	}
+}
+
+// WithRedactedHeaders adds headers whose values are replaced by [Redacted].
+// Authorization, Proxy-Authorization, Cookie and Set-Cookie are always redacted,
+// and so are the matching cookies and the userinfo of request URLs.
+func WithRedactedHeaders(names ...string) Option {
+	return func(r *Recorder) {
+		for _, n := range names {
+			r.redactHeaders[http.CanonicalHeaderKey(n)] = struct{}{}
+		}
+	}
+}
+
+// WithRedactedQuery adds query parameters whose values are replaced by [Redacted].
+func WithRedactedQuery(names ...string) Option {
+	return func(r *Recorder) {
+		for _, n := range names {
+			r.redactQuery[n] = struct{}{}
+		}
+	}
+}
+
+// WithRedactFunc adds a function called on every entry when the log is
+// exported, e.g. to scrub tokens from bodies.
+func WithRedactFunc(fn func(*Entry)) Option {
+	return func(r *Recorder) {
+		r.redactFuncs = append(r.redactFuncs, fn)
+	}
+}
+
+// Recorder is a [choco.PipelineStep] capturing every exchange into a HAR log.
+// Place it first in the pipeline to capture requests as sent by the caller, or
+// last to capture them exactly as they reach the transport.
+// It is safe for concurrent use.
+type Recorder struct {
+	creator       Creator
+	maxBodySize   int64
+	redactHeaders map[string]struct{}
+	redactQuery   map[string]struct{}
+	redactFuncs   []func(*Entry)
+
+	mu      sync.Mutex
+	entries []*Entry
+}
+
+// NewRecorder creates a [Recorder] configured with opts.
+func NewRecorder(opts ...Option) *Recorder {
+	r := &Recorder{
+		creator:     Creator{Name: "choco-go", Version: "dev"},
+		maxBodySize: DefaultMaxBodySize,
+		redactHeaders: map[string]struct{}{
+			"Authorization":       {},
+			"Proxy-Authorization": {},
+			"Cookie":              {},
+			"Set-Cookie":          {},
+		},
+		redactQuery: map[string]struct{}{},
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Name implements [choco.NamedStep].
+func (r *Recorder) Name() string {
+	return "har"
+}
+
+// Do implements [choco.PipelineStep].
+func (r *Recorder) Do(req *choco.Request, next choco.RequestHandlerFunc) (*http.Response, error) {
+	start := time.Now()
+	entry := &Entry{
+		StartedDateTime: start,
+		Request:         r.captureRequest(req),
+		Timings:         Timings{Blocked: -1, DNS: -1, Connect: -1, SSL: -1},
+	}
+
+	resp, err := next(req)
+	wait := time.Since(start)
+	entry.Timings.Wait = millis(wait)
+	entry.Time = millis(wait)
+
+	if err != nil {
+		entry.Response = Response{
+			Cookies:     []Cookie{},
+			Headers:     []NameValue{},
+			Content:     Content{MimeType: "x-unknown"},
+			HeadersSize: -1,
+			BodySize:    -1,
+			Comment:     err.Error(),
+		}
+		r.add(entry)
+		return resp, err
+	}
+
+	entry.Response = captureResponse(resp)
+	r.add(entry)
+	if resp.Body != nil && r.maxBodySize >= 0 {
+		resp.Body = &bodyCapture{
+			ReadCloser: resp.Body,
+			recorder:   r,
+			entry:      entry,
+			start:      start,
+			ready:      start.Add(wait),
+		}
+	}
+	return resp, err
+}
+
+func (r *Recorder) add(e *Entry) {
+	r.mu.Lock()
+	r.entries = append(r.entries, e)
+	r.mu.Unlock()
+}
+
+// HAR returns a redacted snapshot of the entries recorded so far.
+func (r *Recorder) HAR() HAR {
+	r.mu.Lock()
+	entries := make([]Entry, len(r.entries))
+	for i, e := range r.entries {
+		entries[i] = cloneEntry(e)
+	}
+	r.mu.Unlock()
+
+	for i := range entries {
+		r.redact(&entries[i])
+	}
+	return HAR{Log: Log{
+		Version: Version,
+		Creator: r.creator,
+		Entries: entries,
+	}}
+}
+
+// WriteTo writes the HAR document as indented JSON to w.
+func (r *Recorder) WriteTo(w io.Writer) (int64, error) {
+	b, err := json.MarshalIndent(r.HAR(), "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(append(b, '\n'))
+	return int64(n), err
+}
+
+// WriteFile writes the HAR document to the named file, creating or truncating it.
+func (r *Recorder) WriteFile(name string) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if _, err := r.WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Reset discards every recorded entry.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	r.entries = nil
+	r.mu.Unlock()
+}
+
+func (r *Recorder) captureRequest(req *choco.Request) Request {
+	raw := req.Raw()
+	hr := Request{
+		Method:      raw.Method,
+		URL:         raw.URL.String(),
+		HTTPVersion: protoOrDefault(raw.Proto),
+		Cookies:     cookies(raw.Cookies()),
+		Headers:     nameValues(raw.Header),
+		QueryString: nameValues(raw.URL.Query()),
+		HeadersSize: -1,
+		BodySize:    raw.ContentLength,
+	}
+	if raw.Host != "" && raw.Host != raw.URL.Host {
+		hr.Headers = append(hr.Headers, NameValue{Name: "Host", Value: raw.Host})
+	}
+
+	body := req.Body()
+	if body == nil || r.maxBodySize < 0 {
+		if body == nil {
+			hr.BodySize = 0
+		}
+		return hr
+	}
+	if _, err := body.Seek(0, io.SeekStart); err != nil {
+		return hr
+	}
+	data, _ := io.ReadAll(io.LimitReader(body, r.maxBodySize))
+	body.Seek(0, io.SeekStart)
+
+	mimeType := raw.Header.Get(choco.HeaderContentType)
+	hr.PostData = &PostData{MimeType: mimeType, Params: []NameValue{}, Text: string(data)}
+	truncated := int64(len(data)) < raw.ContentLength
+	if truncated {
+		hr.PostData.Comment = "truncated"
+	}
+	if !utf8.Valid(data) {
+		// HAR 1.2 has no encoding field on postData, unlike content: binary
+		// bodies are base64 encoded and flagged in the comment.
+		hr.PostData.Text = base64.StdEncoding.EncodeToString(data)
+		hr.PostData.Comment = "base64"
+		if truncated {
+			hr.PostData.Comment = "truncated, base64"
+		}
+		return hr
+	}
+	if mt, _, _ := mime.ParseMediaType(mimeType); mt == choco.ContentTypeFormURLEncoded && !truncated {
+		if form, err := url.ParseQuery(string(data)); err == nil {
+			hr.PostData.Params = nameValues(form)
+		}
+	}
+	return hr
+}
+
+func captureResponse(resp *http.Response) Response {
+	hr := Response{
+		Status:      resp.StatusCode,
+		StatusText:  http.StatusText(resp.StatusCode),
+		HTTPVersion: protoOrDefault(resp.Proto),
+		Cookies:     cookies(resp.Cookies()),
+		Headers:     nameValues(resp.Header),
+		Content: Content{
+			Size:     0,
+			MimeType: resp.Header.Get(choco.HeaderContentType),
+		},
+		HeadersSize: -1,
+		BodySize:    -1,
+	}
+	if loc := resp.Header.Get(choco.HeaderLocation); loc != "" {
+		hr.RedirectURL = loc
+	}
+	if hr.Content.MimeType == "" {
+		hr.Content.MimeType = "x-unknown"
+	}
+	return hr
+}
+
+func (r *Recorder) redact(e *Entry) {
+	for i, h := range e.Request.Headers {
+		if _, ok := r.redactHeaders[http.CanonicalHeaderKey(h.Name)]; ok {
+			e.Request.Headers[i].Value = Redacted
+		}
+	}
+	for i, h := range e.Response.Headers {
+		if _, ok := r.redactHeaders[http.CanonicalHeaderKey(h.Name)]; ok {
+			e.Response.Headers[i].Value = Redacted
+		}
+	}
+	if _, ok := r.redactHeaders["Cookie"]; ok {
+		for i := range e.Request.Cookies {
+			e.Request.Cookies[i].Value = Redacted
+		}
+	}
+	if _, ok := r.redactHeaders["Set-Cookie"]; ok {
+		for i := range e.Response.Cookies {
+			e.Response.Cookies[i].Value = Redacted
+		}
+	}
+
+	if u, err := url.Parse(e.Request.URL); err == nil && u.User != nil {
+		u.User = url.User(Redacted)
+		e.Request.URL = u.String()
+	}
+
+	if len(r.redactQuery) > 0 {
+		for i, q := range e.Request.QueryString {
+			if _, ok := r.redactQuery[q.Name]; ok {
+				e.Request.QueryString[i].Value = Redacted
+			}
+		}
+		if u, err := url.Parse(e.Request.URL); err == nil {
+			q := u.Query()
+			for name := range r.redactQuery {
+				if q.Has(name) {
+					q.Set(name, Redacted)
+				}
+			}
+			u.RawQuery = q.Encode()
+			e.Request.URL = u.String()
+		}
+	}
+
+	for _, fn := range r.redactFuncs {
+		fn(e)
+	}
+}
+
+// bodyCapture copies up to maxBodySize bytes of a response body into its
+// entry while the caller reads it, and records the receive timing.
+type bodyCapture struct {
+	io.ReadCloser
+	recorder *Recorder
+	entry    *Entry
+	start    time.Time
+	ready    time.Time
+
+	buf  bytes.Buffer
+	size int64
+	done bool
+}
+
+func (b *bodyCapture) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.size += int64(n)
+	if room := b.recorder.maxBodySize - int64(b.buf.Len()); room > 0 {
+		b.buf.Write(p[:min(int64(n), room)])
+	}
+	if err != nil {
+		b.finish()
+	}
+	return n, err
+}
+
+func (b *bodyCapture) Close() error {
+	err := b.ReadCloser.Close()
+	b.finish()
+	return err
+}
+
+func (b *bodyCapture) finish() {
+	if b.done {
+		return
+	}
+	b.done = true
+
+	now := time.Now()
+	b.recorder.mu.Lock()
+	defer b.recorder.mu.Unlock()
+
+	e := b.entry
+	e.Timings.Receive = millis(now.Sub(b.ready))
+	e.Time = millis(now.Sub(b.start))
+	e.Response.BodySize = b.size
+	e.Response.Content.Size = b.size
+
+	data := b.buf.Bytes()
+	if utf8.Valid(data) {
+		e.Response.Content.Text = string(data)
+	} else {
+		e.Response.Content.Text = base64.StdEncoding.EncodeToString(data)
+		e.Response.Content.Encoding = "base64"
+	}
+	if int64(len(data)) < b.size {
+		e.Response.Content.Comment = "truncated"
+	}
+}
+
+func cloneEntry(e *Entry) Entry {
+	c := *e
+	c.Request.Headers = slices.Clone(e.Request.Headers)
+	c.Request.QueryString = slices.Clone(e.Request.QueryString)
+	c.Request.Cookies = slices.Clone(e.Request.Cookies)
+	c.Response.Headers = slices.Clone(e.Response.Headers)
+	c.Response.Cookies = slices.Clone(e.Response.Cookies)
+	if e.Request.PostData != nil {
+		pd := *e.Request.PostData
+		pd.Params = slices.Clone(pd.Params)
+		c.Request.PostData = &pd
+	}
+	return c
+}
+
+func nameValues[M ~map[string][]string](m M) []NameValue {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	out := []NameValue{}
+	for _, k := range keys {
+		for _, v := range m[k] {
+			out = append(out, NameValue{Name: k, Value: v})
+		}
+	}
+	return out
+}
+
+func cookies(cs []*http.Cookie) []Cookie {
+	out := make([]Cookie, 0, len(cs))
+	for _, c := range cs {
+		hc := Cookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Path:     c.Path,
+			Domain:   c.Domain,
+			HTTPOnly: c.HttpOnly,
+			Secure:   c.Secure,
+		}
+		if !c.Expires.IsZero() {
+			exp := c.Expires
+			hc.Expires = &exp
+		}
+		out = append(out, hc)
+	}
+	return out
+}
+
+func protoOrDefault(proto string) string {
+	if proto == "" {
+		return "HTTP/1.1"
+	}
+	return proto
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
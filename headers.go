@@ -4,8 +4,11 @@ import "encoding/base64"
 
 const (
 	ContentTypeAppJSON   = "application/json"
+	ContentTypeAppNDJSON = "application/x-ndjson"
 	ContentTypeAppXML    = "application/xml"
 	ContentTypeTextPlain = "text/plain"
+
+	ContentTypeFormURLEncoded = "application/x-www-form-urlencoded"
 )
 
 type AuthSchema string
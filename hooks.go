@@ -0,0 +1,116 @@
+package choco
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// [Observer] watches the requests executed by a [Pipeline] without taking part
+// in the chain. Each hook is optional and fires at a fixed point, whatever
+// steps the pipeline has:
+//
+//	OnRequest                 before the first step
+//	OnRetry                   before every attempt after the first
+//	OnSend                    right before Transport.Send
+//	OnReceive                 after Transport.Send returns
+//	OnResponse or OnError     after the first step returns
+//
+// OnResponse or OnError always follows OnRequest: requests failing before the
+// first step, e.g. when a [Request.SetBodyAs] value cannot be encoded, are
+// not observed.
+//
+// Hooks receive copies of the request and response data, and a panicking
+// hook is recovered, so observers cannot alter or break the execution. Hooks
+// run synchronously and should return quickly.
+type Observer struct {
+	OnRequest  func(HookEvent)
+	OnRetry    func(HookEvent)
+	OnSend     func(HookEvent)
+	OnReceive  func(HookEvent)
+	OnResponse func(HookEvent)
+	OnError    func(HookEvent)
+}
+
+// [HookEvent] describes a request at the point an [Observer] hook fires.
+type HookEvent struct {
+	Method string
+	URL    string
+	Header http.Header
+
+	// Attempt numbers the transport calls from 1. It is zero in OnRequest, and
+	// in OnRetry, OnSend and OnReceive it is the number of the call at hand.
+	Attempt int
+
+	// Response data, set by OnReceive and OnResponse
+	StatusCode     int
+	ResponseHeader http.Header
+
+	// Err is set by OnReceive and OnError when the call failed.
+	Err error
+
+	// Start is when Execute was called, and Elapsed the time since then.
+	Start   time.Time
+	Elapsed time.Duration
+
+	// AttemptElapsed is the duration of the transport call, set by OnReceive.
+	AttemptElapsed time.Duration
+}
+
+// execution tracks one call to [Pipeline.Execute] for the observers.
+type execution struct {
+	observers []Observer
+	start     time.Time
+	attempts  atomic.Int32
+}
+
+// event builds a [HookEvent] from copies of req and resp.
+func (e *execution) event(req *http.Request, resp *http.Response, err error) HookEvent {
+	ev := HookEvent{
+		Attempt: int(e.attempts.Load()),
+		Err:     err,
+		Start:   e.start,
+		Elapsed: time.Since(e.start),
+	}
+	if req != nil {
+		ev.Method = req.Method
+		ev.URL = req.URL.String()
+		ev.Header = req.Header.Clone()
+	}
+	if resp != nil {
+		ev.StatusCode = resp.StatusCode
+		ev.ResponseHeader = resp.Header.Clone()
+	}
+	return ev
+}
+
+// fire calls the hook selected by pick on every observer.
+func (e *execution) fire(pick func(Observer) func(HookEvent), ev HookEvent) {
+	for _, o := range e.observers {
+		if hook := pick(o); hook != nil {
+			callHook(hook, ev)
+		}
+	}
+}
+
+func callHook(hook func(HookEvent), ev HookEvent) {
+	defer func() { _ = recover() }()
+	hook(ev)
+}
+
+// observeSend wraps send with the OnRetry, OnSend and OnReceive hooks.
+func (e *execution) observeSend(send RequestHandlerFunc) RequestHandlerFunc {
+	return func(r *Request) (*http.Response, error) {
+		if e.attempts.Add(1) > 1 {
+			e.fire(func(o Observer) func(HookEvent) { return o.OnRetry }, e.event(r.Raw(), nil, nil))
+		}
+		e.fire(func(o Observer) func(HookEvent) { return o.OnSend }, e.event(r.Raw(), nil, nil))
+
+		sent := time.Now()
+		resp, err := send(r)
+		ev := e.event(r.Raw(), resp, err)
+		ev.AttemptElapsed = time.Since(sent)
+		e.fire(func(o Observer) func(HookEvent) { return o.OnReceive }, ev)
+		return resp, err
+	}
+}
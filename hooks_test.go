@@ -0,0 +1,115 @@
+package choco
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"testing"
+
+	"nyxze/choco-go/chocotest"
+)
+
+// retryTwice calls next up to three times while it fails.
+func retryTwice() PipelineStep {
+	return PipelineStepFunc(func(req *Request, next RequestHandlerFunc) (*http.Response, error) {
+		var (
+			resp *http.Response
+			err  error
+		)
+		for range 3 {
+			if resp, err = next(req); err == nil {
+				break
+			}
+		}
+		return resp, err
+	})
+}
+
+func TestObserver(t *testing.T) {
+	tr := chocotest.NewTransport()
+	tr.On("GET", "/flaky").Respond(chocotest.Error(errors.New("reset")), chocotest.Status(http.StatusCreated))
+	tr.On("GET", "/down").RespondError(errors.New("down"))
+
+	var events []string
+	record := func(name string) func(HookEvent) {
+		return func(ev HookEvent) {
+			events = append(events, fmt.Sprintf("%s:%d:%d", name, ev.Attempt, ev.StatusCode))
+		}
+	}
+	observer := Observer{
+		OnRequest:  record("request"),
+		OnRetry:    record("retry"),
+		OnSend:     record("send"),
+		OnReceive:  record("receive"),
+		OnResponse: record("response"),
+		OnError:    record("error"),
+	}
+	tamper := Observer{
+		OnSend: func(ev HookEvent) {
+			ev.Header.Set("X-Tampered", "1")
+			panic("observer bug")
+		},
+	}
+	p, err := NewPipeline(
+		WithCustomTransport(tr),
+		WithBaseURL("http://www.example.com"),
+		WithSteps(retryTwice()),
+		WithObserver(tamper),
+		WithObserver(observer),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path    string
+		wantErr bool
+		want    []string
+	}{
+		{"/flaky", false, []string{"request:0:0", "send:1:0", "receive:1:0", "retry:2:0", "send:2:0", "receive:2:201", "response:2:201"}},
+		{"/down", true, []string{"request:0:0", "send:1:0", "receive:1:0", "retry:2:0", "send:2:0", "receive:2:0", "retry:3:0", "send:3:0", "receive:3:0", "error:3:0"}},
+	}
+	for _, tt := range tests {
+		events = nil
+		req, err := NewRequest(context.Background(), "GET", tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = p.Execute(req)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("%s: err = %v", tt.path, err)
+		}
+		if !slices.Equal(events, tt.want) {
+			t.Errorf("%s: events =\n%v\nwant\n%v", tt.path, events, tt.want)
+		}
+		if lastRequest(t, tr).Header.Get("X-Tampered") != "" {
+			t.Errorf("%s: observer modified the request", tt.path)
+		}
+	}
+}
+
+func TestObserverPrepareError(t *testing.T) {
+	var events int
+	count := func(HookEvent) { events++ }
+	p, err := NewPipeline(
+		WithCustomTransport(pingTransport()),
+		WithObserver(Observer{OnRequest: count, OnResponse: count, OnError: count}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := NewRequest(context.Background(), "POST", testURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetContentType("application/x-unknown")
+	req.SetBodyAs(1)
+	if _, err := p.Execute(req); err == nil {
+		t.Fatal("expected error for content type without codec")
+	}
+	if events != 0 {
+		t.Errorf("got %d events for a request that could not be prepared, want none", events)
+	}
+}
@@ -0,0 +1,63 @@
+package json
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"nyxze/choco-go"
+	"sync"
+)
+
+// SetSeqBody streams the values of seq as newline-delimited JSON (NDJSON)
+// using [choco.Request.SetStreamBody].
+//
+// Values are encoded on the fly while the request body is read, so seq is
+// only iterated once the request is sent. Iteration stops early if the body
+// is closed, e.g. when the request is cancelled. The size is unknown, so the
+// body is sent with chunked transfer encoding.
+func SetSeqBody[T any](req *choco.Request, seq iter.Seq[T]) error {
+	if seq == nil {
+		return fmt.Errorf("error setting NDJSON body: seq is nil")
+	}
+	return req.SetStreamBody(&seqReader[T]{seq: seq}, -1, choco.ContentTypeAppNDJSON)
+}
+
+// seqReader starts encoding its sequence into a pipe on the first Read.
+type seqReader[T any] struct {
+	seq  iter.Seq[T]
+	once sync.Once
+	pr   *io.PipeReader
+}
+
+func (s *seqReader[T]) start() {
+	pr, pw := io.Pipe()
+	s.pr = pr
+	go func() {
+		enc := json.NewEncoder(pw)
+		var err error
+		for v := range s.seq {
+			if err = enc.Encode(v); err != nil {
+				break
+			}
+		}
+		pw.CloseWithError(err)
+	}()
+}
+
+func (s *seqReader[T]) Read(p []byte) (int, error) {
+	s.once.Do(s.start)
+	if s.pr == nil {
+		return 0, io.ErrClosedPipe
+	}
+	return s.pr.Read(p)
+}
+
+func (s *seqReader[T]) Close() error {
+	// Prevent a later Read from starting the producer.
+	s.once.Do(func() {})
+	if s.pr == nil {
+		return nil
+	}
+	return s.pr.Close()
+}
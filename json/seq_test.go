@@ -0,0 +1,60 @@
+package json_test
+
+import (
+	"context"
+	"io"
+	"slices"
+	"testing"
+
+	"nyxze/choco-go"
+	"nyxze/choco-go/json"
+)
+
+func TestSetSeqBody(t *testing.T) {
+	type item struct {
+		ID int `json:"id"`
+	}
+	req, err := choco.NewRequest(context.Background(), "POST", "http://www.example.com/bulk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	items := []item{{1}, {2}, {3}}
+	if err := json.SetSeqBody(req, slices.Values(items)); err != nil {
+		t.Fatal(err)
+	}
+	raw := req.Raw()
+	if ct := raw.Header.Get(choco.HeaderContentType); ct != choco.ContentTypeAppNDJSON {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	b, err := io.ReadAll(raw.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "{\"id\":1}\n{\"id\":2}\n{\"id\":3}\n"; string(b) != want {
+		t.Errorf("body = %q, want %q", b, want)
+	}
+}
+
+func TestSetSeqBodyStopsOnClose(t *testing.T) {
+	req, err := choco.NewRequest(context.Background(), "POST", "http://www.example.com/bulk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stopped := make(chan int)
+	infinite := func(yield func(int) bool) {
+		i := 0
+		for yield(i) {
+			i++
+		}
+		stopped <- i
+	}
+	if err := json.SetSeqBody(req, infinite); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 4)
+	if _, err := req.Raw().Body.Read(buf); err != nil {
+		t.Fatal(err)
+	}
+	req.Close()
+	<-stopped
+}
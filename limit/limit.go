@@ -0,0 +1,215 @@
+// Package limit provides a [choco.PipelineStep] protecting callers from
+// misbehaving upstreams: it caps the size of response bodies and cancels
+// requests whose bodies stop making progress.
+//
+//	step := limit.NewStep(
+//	    limit.WithMaxBodySize(10<<20),
+//	    limit.WithIdleTimeout(30*time.Second),
+//	)
+//	pipeline, err := choco.NewPipeline(choco.WithSteps(step))
+//
+// The idle timeout is especially useful for long-lived streams such as
+// server-sent events, which can otherwise hang silently.
+package limit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"nyxze/choco-go"
+)
+
+// ErrBodyTooLarge is matched by every [*BodyTooLargeError] with [errors.Is].
+var ErrBodyTooLarge = errors.New("limit: response body too large")
+
+// ErrIdleTimeout is returned when no response bytes arrive within the idle timeout.
+var ErrIdleTimeout = errors.New("limit: idle read timeout")
+
+// BodyTooLargeError is returned when a response body exceeds the configured limit.
+type BodyTooLargeError struct {
+	// Limit is the configured maximum body size.
+	Limit int64
+	// ContentLength is the size announced by the server, or -1 if the limit
+	// was hit while reading.
+	ContentLength int64
+}
+
+func (e *BodyTooLargeError) Error() string {
+	if e.ContentLength >= 0 {
+		return fmt.Sprintf("limit: response body of %d bytes exceeds limit of %d bytes", e.ContentLength, e.Limit)
+	}
+	return fmt.Sprintf("limit: response body exceeds limit of %d bytes", e.Limit)
+}
+
+// Is reports whether target is [ErrBodyTooLarge].
+func (e *BodyTooLargeError) Is(target error) bool {
+	return target == ErrBodyTooLarge
+}
+
+// Option configures a [Step].
+type Option func(*Step)
+
+// WithMaxBodySize limits response bodies to n bytes. Bodies announcing a
+// larger Content-Length are rejected before being read; others fail with a
+// [*BodyTooLargeError] once the limit is passed. Zero disables the limit.
+func WithMaxBodySize(n int64) Option {
+	return func(s *Step) {
+		s.maxBodySize = n
+	}
+}
+
+// WithIdleTimeout cancels the request when no response bytes arrive for d,
+// either while waiting for the response headers or between two body reads.
+// The failing call returns [ErrIdleTimeout]. Zero disables the timeout.
+func WithIdleTimeout(d time.Duration) Option {
+	return func(s *Step) {
+		s.idleTimeout = d
+	}
+}
+
+// Step is a [choco.PipelineStep] enforcing response body limits.
+// It is safe for concurrent use.
+type Step struct {
+	maxBodySize int64
+	idleTimeout time.Duration
+}
+
+// NewStep creates a limit [Step].
+func NewStep(opts ...Option) *Step {
+	s := &Step{}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Name implements [choco.NamedStep].
+func (s *Step) Name() string {
+	return "limit"
+}
+
+// Do implements [choco.PipelineStep].
+func (s *Step) Do(req *choco.Request, next choco.RequestHandlerFunc) (*http.Response, error) {
+	var w *watchdog
+	if s.idleTimeout > 0 {
+		ctx, cancel := context.WithCancelCause(req.Raw().Context())
+		w = &watchdog{ctx: ctx, cancel: cancel, timeout: s.idleTimeout}
+		w.timer = time.AfterFunc(s.idleTimeout, func() { cancel(ErrIdleTimeout) })
+		req = req.WithContext(ctx)
+	}
+
+	resp, err := next(req)
+	if err != nil || resp == nil {
+		if w != nil {
+			err = w.err(err)
+			w.stop()
+		}
+		return resp, err
+	}
+
+	if s.maxBodySize > 0 && resp.ContentLength > s.maxBodySize {
+		if resp.Body != nil {
+			resp.Body.Close()
+		}
+		if w != nil {
+			w.stop()
+		}
+		return nil, &BodyTooLargeError{Limit: s.maxBodySize, ContentLength: resp.ContentLength}
+	}
+	if resp.Body == nil {
+		if w != nil {
+			w.stop()
+		}
+		return resp, nil
+	}
+
+	if w != nil {
+		w.kick()
+	}
+	resp.Body = &limitedBody{ReadCloser: resp.Body, limit: s.maxBodySize, watchdog: w}
+	return resp, nil
+}
+
+// watchdog cancels the request context when it is not kicked in time.
+type watchdog struct {
+	ctx     context.Context
+	cancel  context.CancelCauseFunc
+	timeout time.Duration
+
+	mu    sync.Mutex
+	timer *time.Timer
+}
+
+func (w *watchdog) kick() {
+	w.mu.Lock()
+	w.timer.Reset(w.timeout)
+	w.mu.Unlock()
+}
+
+func (w *watchdog) stop() {
+	w.mu.Lock()
+	w.timer.Stop()
+	w.mu.Unlock()
+	w.cancel(context.Canceled)
+}
+
+// err replaces err by [ErrIdleTimeout] when the watchdog fired.
+func (w *watchdog) err(err error) error {
+	if err != nil && errors.Is(context.Cause(w.ctx), ErrIdleTimeout) {
+		return ErrIdleTimeout
+	}
+	return err
+}
+
+// limitedBody enforces the body size limit and kicks the watchdog on every read.
+type limitedBody struct {
+	io.ReadCloser
+	limit    int64
+	read     int64
+	watchdog *watchdog
+	err      error
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	if b.limit > 0 && int64(len(p)) > b.limit-b.read+1 {
+		// Read one byte past the limit to detect oversized bodies.
+		p = p[:b.limit-b.read+1]
+	}
+
+	n, err := b.ReadCloser.Read(p)
+	b.read += int64(n)
+	if b.limit > 0 && b.read > b.limit {
+		n -= int(b.read - b.limit)
+		b.read = b.limit
+		err = &BodyTooLargeError{Limit: b.limit, ContentLength: -1}
+	}
+
+	if b.watchdog != nil {
+		switch {
+		case err != nil:
+			err = b.watchdog.err(err)
+			b.watchdog.stop()
+		case n > 0:
+			b.watchdog.kick()
+		}
+	}
+	if err != nil {
+		b.err = err
+	}
+	return n, err
+}
+
+func (b *limitedBody) Close() error {
+	if b.watchdog != nil {
+		b.watchdog.stop()
+	}
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,137 @@
+package limit_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"nyxze/choco-go"
+	"nyxze/choco-go/chocotest"
+	"nyxze/choco-go/limit"
+)
+
+func execute(t *testing.T, tr choco.Transport, step *limit.Step) (*http.Response, error) {
+	t.Helper()
+	p, err := choco.NewPipeline(choco.WithCustomTransport(tr), choco.WithSteps(step))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := choco.NewRequest(context.Background(), "GET", "http://www.example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p.Execute(req)
+}
+
+func TestMaxBodySize(t *testing.T) {
+	step := limit.NewStep(limit.WithMaxBodySize(10))
+
+	// Announced size over the limit is rejected before reading.
+	tr := chocotest.NewTransport()
+	tr.On("GET", "").Respond(chocotest.Text(http.StatusOK, strings.Repeat("x", 11)))
+	_, err := execute(t, tr, step)
+	var tooLarge *limit.BodyTooLargeError
+	if !errors.As(err, &tooLarge) || tooLarge.ContentLength != 11 || !errors.Is(err, limit.ErrBodyTooLarge) {
+		t.Errorf("err = %v, want BodyTooLargeError with ContentLength 11", err)
+	}
+
+	// Unknown size is cut while reading.
+	resp, err := execute(t, streamTransport{body: strings.NewReader(strings.Repeat("x", 25))}, step)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(resp.Body)
+	if !errors.Is(err, limit.ErrBodyTooLarge) || len(b) != 10 {
+		t.Errorf("read %d bytes, err = %v; want 10 bytes and ErrBodyTooLarge", len(b), err)
+	}
+
+	// Bodies within the limit are untouched.
+	resp, err = execute(t, streamTransport{body: strings.NewReader("0123456789")}, step)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, err := io.ReadAll(resp.Body); err != nil || string(b) != "0123456789" {
+		t.Errorf("read %q, %v", b, err)
+	}
+}
+
+func TestIdleTimeout(t *testing.T) {
+	step := limit.NewStep(limit.WithIdleTimeout(20 * time.Millisecond))
+
+	pr, pw := io.Pipe()
+	go func() {
+		// Keep the stream alive for a while, then go silent.
+		for range 5 {
+			pw.Write([]byte("data: ping\n\n"))
+			time.Sleep(5 * time.Millisecond)
+		}
+	}()
+	defer pw.Close()
+
+	resp, err := execute(t, streamTransport{body: pr}, step)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(resp.Body)
+	if !errors.Is(err, limit.ErrIdleTimeout) {
+		t.Errorf("err = %v, want ErrIdleTimeout", err)
+	}
+	if got := strings.Count(string(b), "ping"); got != 5 {
+		t.Errorf("received %d events before the timeout, want 5", got)
+	}
+
+	// Waiting too long for the headers also times out.
+	_, err = execute(t, streamTransport{delay: time.Second}, step)
+	if !errors.Is(err, limit.ErrIdleTimeout) {
+		t.Errorf("err = %v, want ErrIdleTimeout", err)
+	}
+}
+
+// streamTransport answers with body, closing it when the request context is done.
+type streamTransport struct {
+	body  io.Reader
+	delay time.Duration
+}
+
+func (s streamTransport) Send(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	if s.delay > 0 {
+		select {
+		case <-time.After(s.delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	body := &ctxReader{ctx: ctx, r: s.body}
+	return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(body), ContentLength: -1}, nil
+}
+
+// ctxReader fails pending reads once ctx is done, like a network connection would.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *ctxReader) Read(p []byte) (int, error) {
+	type result struct {
+		n   int
+		err error
+	}
+	ch := make(chan result, 1)
+	buf := make([]byte, len(p))
+	go func() {
+		n, err := c.r.Read(buf)
+		ch <- result{n, err}
+	}()
+	select {
+	case r := <-ch:
+		copy(p, buf[:r.n])
+		return r.n, r.err
+	case <-c.ctx.Done():
+		return 0, c.ctx.Err()
+	}
+}
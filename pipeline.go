@@ -2,7 +2,12 @@ package choco
 
 import (
 	"fmt"
+	"maps"
 	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
 )
 
 // [Pipeline] defines a chain of [PipelineStep]s that process a [Request]
@@ -10,6 +15,24 @@ import (
 type Pipeline struct {
 	steps     []PipelineStep
 	transport Transport
+
+	// Steps by phase and pending insertions, flattened into steps by NewPipeline
+	phases     [numPhases][]PipelineStep
+	inserts    []stepInsert
+	stepPhases []Phase
+
+	// Applied to every request before the first step runs
+	baseURL        *url.URL
+	defaultHeaders http.Header
+
+	// Encodes SetBodyAs values and decodes responses
+	codecs *CodecRegistry
+
+	// Notified at fixed points of every execution
+	observers []Observer
+
+	// Transports by URL scheme, used instead of transport
+	schemes map[string]Transport
 }
 
 // [PipelineStep] represents a single unit of work in a [Pipeline].
@@ -45,17 +68,54 @@ func NewPipeline(opts ...PipelineOption) (Pipeline, error) {
 		transport: defaultTransport{
 			client: http.DefaultClient,
 		},
+		codecs: DefaultCodecs(),
 	}
 	var err error
 	for i := range opts {
 		err = opts[i](&pipeline)
 		if err != nil {
-			return Pipeline{}, NewError("pipeline: failed to apply pipeline options")
+			return Pipeline{}, NewError("pipeline: failed to apply pipeline options: %w", err)
 		}
 	}
+	if err := pipeline.buildSteps(); err != nil {
+		return Pipeline{}, err
+	}
 	return pipeline, nil
 }
 
+// With returns a new [Pipeline] made of the configuration of p with opts applied
+// on top, leaving p untouched.
+//
+// Steps, insertions, default headers and codecs are copied, so options never
+// modify p. The transport is shared, and with it the connection pool.
+//
+// Example:
+//
+//	base, err := NewPipeline(WithSteps(logging, retry))
+//	tenant, err := base.With(WithPhaseSteps(PerRetry, tenantAuth))
+func (p Pipeline) With(opts ...PipelineOption) (Pipeline, error) {
+	derived := p
+	for ph := range derived.phases {
+		derived.phases[ph] = slices.Clone(p.phases[ph])
+	}
+	derived.inserts = slices.Clone(p.inserts)
+	derived.observers = slices.Clone(p.observers)
+	derived.schemes = maps.Clone(p.schemes)
+	derived.defaultHeaders = p.defaultHeaders.Clone()
+	if p.codecs != nil {
+		derived.codecs = p.codecs.Clone()
+	}
+	for i := range opts {
+		if err := opts[i](&derived); err != nil {
+			return Pipeline{}, NewError("pipeline: failed to apply pipeline options: %w", err)
+		}
+	}
+	if err := derived.buildSteps(); err != nil {
+		return Pipeline{}, err
+	}
+	return derived, nil
+}
+
 // Execute runs the [Pipeline], passing the [Request] through all registered [PipelineStep]s.
 // Each step may inspect, modify, short-circuit, or pass the request to the next step.
 // If no step calls [next], the pipeline will not proceed and an error will be returned.
@@ -67,24 +127,115 @@ func (p Pipeline) Execute(req *Request) (*http.Response, error) {
 	if p.transport == nil {
 		return nil, NewError("pipeline transport is not set")
 	}
-	handler := p.sendRequest
+	var exec *execution
+	send := p.sendRequest
+	if len(p.observers) > 0 {
+		exec = &execution{observers: p.observers, start: time.Now()}
+		send = exec.observeSend(send)
+	}
+	// Observers are not notified of requests that cannot be prepared, so that
+	// OnError always follows OnRequest.
+	if err := p.prepareRequest(req); err != nil {
+		return nil, err
+	}
+	handler := buildChain(req, req.extraSteps, send)
+	handler = buildChain(req, p.steps, handler)
+
+	if exec == nil {
+		return handler(req)
+	}
+	exec.fire(func(o Observer) func(HookEvent) { return o.OnRequest }, exec.event(req.Raw(), nil, nil))
+	resp, err := handler(req)
+	if err != nil {
+		exec.fire(func(o Observer) func(HookEvent) { return o.OnError }, exec.event(req.Raw(), resp, err))
+	} else {
+		exec.fire(func(o Observer) func(HookEvent) { return o.OnResponse }, exec.event(req.Raw(), resp, nil))
+	}
+	return resp, err
+}
 
-	for i := len(p.steps) - 1; i >= 0; i-- {
-		handler = wrapStep(p.steps[i], handler)
+// prepareRequest applies the pipeline-wide request settings
+// (base URL, default headers, body codecs) before the request enters the first step.
+func (p Pipeline) prepareRequest(cReq *Request) error {
+	req := cReq.Raw()
+	if req == nil {
+		return NewError("request: missing inner *http.Request")
+	}
+	if p.baseURL != nil && !req.URL.IsAbs() && req.URL.Host == "" {
+		req.URL = resolveBaseURL(p.baseURL, req.URL)
+		req.Host = ""
 	}
+	for key, values := range p.defaultHeaders {
+		if len(req.Header.Values(key)) > 0 {
+			continue
+		}
+		if req.Header == nil {
+			req.Header = http.Header{}
+		}
+		req.Header[key] = append([]string(nil), values...)
+	}
+	if cReq.bodyValue != nil {
+		codecs := p.codecs
+		if codecs == nil {
+			codecs = DefaultCodecs()
+		}
+		return cReq.encodeBodyValue(codecs)
+	}
+	return nil
+}
 
-	return handler(req)
+// resolveBaseURL appends the path of ref to the path of base, so that a base of
+// https://api.example.com/v1 and a reference of /users give https://api.example.com/v1/users.
+// The query and fragment of ref are kept.
+func resolveBaseURL(base, ref *url.URL) *url.URL {
+	u := *base
+	u.Path = joinURLPath(base.Path, ref.Path)
+	if base.RawPath != "" || ref.RawPath != "" {
+		u.RawPath = joinURLPath(base.EscapedPath(), ref.EscapedPath())
+	} else {
+		u.RawPath = ""
+	}
+	u.RawQuery = ref.RawQuery
+	u.Fragment = ref.Fragment
+	u.RawFragment = ref.RawFragment
+	return &u
+}
+
+func joinURLPath(base, ref string) string {
+	if ref == "" {
+		return base
+	}
+	return strings.TrimSuffix(base, "/") + "/" + strings.TrimPrefix(ref, "/")
 }
 
 func (p Pipeline) sendRequest(cReq *Request) (*http.Response, error) {
 	req := cReq.Raw()
-	if req.URL.Host == "" {
+	tr, err := p.transportFor(req.URL.Scheme)
+	if err != nil {
+		return nil, err
+	}
+	if (req.URL.Scheme == "http" || req.URL.Scheme == "https") && req.URL.Host == "" {
 		return nil, NewError("no Host in request URL")
 	}
-	if !(req.URL.Scheme == "http" || req.URL.Scheme == "https") {
-		return nil, fmt.Errorf("unsupported protocol scheme %s", req.URL.Scheme)
+	return tr.Send(req)
+}
+
+// transportFor returns the transport registered for scheme with [WithSchemeTransport],
+// falling back to the pipeline transport for http, https and the schemes it supports.
+func (p Pipeline) transportFor(scheme string) (Transport, error) {
+	if tr, ok := p.schemes[scheme]; ok {
+		return tr, nil
+	}
+	if scheme == "http" || scheme == "https" || supportsScheme(p.transport, scheme) {
+		return p.transport, nil
 	}
-	return p.transport.Send(req)
+	return nil, fmt.Errorf("unsupported protocol scheme %q", scheme)
+}
+
+// supportsScheme reports whether tr handles scheme through [SchemeTransport].
+func supportsScheme(tr Transport, scheme string) bool {
+	st, ok := tr.(SchemeTransport)
+	return ok && st.SupportsScheme(scheme)
 }
 
 // wrapStep composes a [PipelineStep] around a [RequestHandlerFunc].
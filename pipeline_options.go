@@ -1,5 +1,11 @@
 package choco
 
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
 // PipelineOption defines a functional option for configuring a Pipeline.
 // These options are applied in order during Pipeline construction via NewPipeline.
 type PipelineOption func(*Pipeline) error
@@ -16,7 +22,8 @@ type PipelineOption func(*Pipeline) error
 //	    WithSteps(...),
 //	)
 //
-// If omitted, the pipeline will default to using http.DefaultClient.
+// If omitted, the pipeline will default to using http.DefaultClient, or to the client
+// built by [WithHTTPClientOptions].
 //
 // Parameters:
 //   - tr: A Transport implementation that defines how to send the final *http.Request.
@@ -30,7 +37,8 @@ func WithCustomTransport(tr Transport) PipelineOption {
 	}
 }
 
-// WithSteps appends one or more PipelineStep implementations to the pipeline.
+// WithSteps appends one or more PipelineStep implementations to the [PerCall] phase
+// of the pipeline.
 //
 // These steps will be executed in the order they are provided. Each step can mutate,
 // inspect, or wrap the request/response lifecycle.
@@ -52,7 +60,90 @@ func WithCustomTransport(tr Transport) PipelineOption {
 //   - A PipelineOptions function to be used with NewPipeline.
 func WithSteps(steps ...PipelineStep) PipelineOption {
 	return func(p *Pipeline) error {
-		p.steps = append(p.steps, steps...)
+		return WithPhaseSteps(PerCall, steps...)(p)
+	}
+}
+
+// WithPhaseSteps appends one or more PipelineStep implementations to a [Phase] of the pipeline.
+//
+// Steps of an earlier phase always wrap steps of later phases, so options can be
+// given in any order: a signing step added to [BeforeTransport] runs after every
+// [PerCall] step, even those added by a later option.
+//
+// Example:
+//
+//	pipeline, err := NewPipeline(
+//	    WithPhaseSteps(BeforeTransport, Named("sign", signingStep)),
+//	    WithPhaseSteps(PerRetry, Named("auth", authStep)),
+//	    WithSteps(Named("logging", loggingStep), Named("retry", retryStep)),
+//	)
+//	// logging -> retry -> auth -> sign -> transport
+//
+// Parameters:
+//   - phase: The phase the steps belong to.
+//   - steps: One or more PipelineStep instances to append to the phase.
+//
+// Returns:
+//   - A [PipelineOption] function to be used with [NewPipeline].
+func WithPhaseSteps(phase Phase, steps ...PipelineStep) PipelineOption {
+	return func(p *Pipeline) error {
+		if phase < 0 || phase >= numPhases {
+			return NewError("pipeline: unknown phase %v", phase)
+		}
+		p.phases[phase] = append(p.phases[phase], steps...)
+		return nil
+	}
+}
+
+// InsertBefore places one or more steps right before the step named name (see [NamedStep]),
+// in the same phase.
+//
+// Insertions are resolved once all the options are applied, so the named step may be
+// added by a later option. [NewPipeline] fails when no step has that name.
+//
+// Example:
+//
+//	pipeline, err := NewPipeline(
+//	    InsertBefore("auth", Named("refresh", tokenRefreshStep)),
+//	    WithSteps(Named("retry", retryStep), Named("auth", authStep)),
+//	)
+//	// retry -> refresh -> auth -> transport
+//
+// Parameters:
+//   - name: The name of the step to insert before.
+//   - steps: One or more PipelineStep instances to insert.
+//
+// Returns:
+//   - A [PipelineOption] function to be used with [NewPipeline].
+func InsertBefore(name string, steps ...PipelineStep) PipelineOption {
+	return func(p *Pipeline) error {
+		p.inserts = append(p.inserts, stepInsert{target: name, steps: steps})
+		return nil
+	}
+}
+
+// InsertAfter places one or more steps right after the step named name (see [NamedStep]),
+// in the same phase.
+//
+// Like [InsertBefore], insertions are resolved once all the options are applied.
+//
+// Example:
+//
+//	pipeline, err := NewPipeline(
+//	    WithSteps(Named("logging", loggingStep), Named("retry", retryStep)),
+//	    InsertAfter("logging", Named("tracing", tracingStep)),
+//	)
+//	// logging -> tracing -> retry -> transport
+//
+// Parameters:
+//   - name: The name of the step to insert after.
+//   - steps: One or more PipelineStep instances to insert.
+//
+// Returns:
+//   - A [PipelineOption] function to be used with [NewPipeline].
+func InsertAfter(name string, steps ...PipelineStep) PipelineOption {
+	return func(p *Pipeline) error {
+		p.inserts = append(p.inserts, stepInsert{target: name, after: true, steps: steps})
 		return nil
 	}
 }
@@ -88,3 +179,245 @@ func WithStepFuncs(funcs ...PipelineStepFunc) PipelineOption {
 	}
 	return WithSteps(steps...)
 }
+
+// WithHTTPClientOptions makes the pipeline send requests with its own [http.Client],
+// configured by opts, instead of [http.DefaultClient].
+//
+// The client gets its own transport and connection pool, owned by the pipeline:
+// pipelines derived with [Pipeline.With] share it, and [Pipeline.CloseIdleConnections]
+// releases its idle connections. This option replaces any transport set before it,
+// and is itself replaced by a later [WithCustomTransport].
+//
+// Example:
+//
+//	pipeline, err := NewPipeline(
+//	    WithHTTPClientOptions(HTTPClientOptions{
+//	        DialTimeout:           5 * time.Second,
+//	        ResponseHeaderTimeout: 30 * time.Second,
+//	        MaxIdleConnsPerHost:   16,
+//	        RootCAs:               internalCAs,
+//	        DisableHTTP2:          true,
+//	    }),
+//	)
+//
+// Parameters:
+//   - opts: The client settings. Zero fields keep the defaults of [http.DefaultTransport].
+//
+// Returns:
+//   - A [PipelineOption] function to be used with [NewPipeline].
+func WithHTTPClientOptions(opts HTTPClientOptions) PipelineOption {
+	return func(p *Pipeline) error {
+		if opts.Timeout < 0 || opts.DialTimeout < 0 || opts.TLSHandshakeTimeout < 0 ||
+			opts.ResponseHeaderTimeout < 0 || opts.IdleConnTimeout < 0 {
+			return NewError("pipeline: HTTP client timeouts must not be negative")
+		}
+		p.transport = defaultTransport{client: newHTTPClient(opts)}
+		return nil
+	}
+}
+
+// WithBaseURL sets the base URL that relative request endpoints are resolved against.
+//
+// The path of a relative endpoint is appended to the path of the base URL, and its
+// query string is kept. Requests created with an absolute URL are left untouched.
+//
+// Example:
+//
+//	pipeline, err := NewPipeline(
+//	    WithBaseURL("https://api.example.com/v1"),
+//	)
+//	req, err := NewRequest(ctx, "GET", "/users?page=2")
+//	// GET https://api.example.com/v1/users?page=2
+//
+// Parameters:
+//   - rawURL: An absolute URL with a scheme and a host.
+//
+// Returns:
+//   - A [PipelineOption] function to be used with [NewPipeline].
+func WithBaseURL(rawURL string) PipelineOption {
+	return func(p *Pipeline) error {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return err
+		}
+		if !u.IsAbs() || u.Host == "" {
+			return NewError("pipeline: base URL %q must have a scheme and a host", rawURL)
+		}
+		p.baseURL = u
+		return nil
+	}
+}
+
+// WithDefaultHeaders sets headers that are added to every request executed by the pipeline.
+//
+// A default header is only applied when the request does not already carry a value
+// for that key, so headers set on the [Request] always win. Calling this option more
+// than once merges the headers, later values replacing earlier ones.
+//
+// Example:
+//
+//	pipeline, err := NewPipeline(
+//	    WithDefaultHeaders(http.Header{
+//	        HeaderAccept: {ContentTypeAppJSON},
+//	    }),
+//	)
+//
+// Parameters:
+//   - headers: The headers to apply to requests that don't set them.
+//
+// Returns:
+//   - A [PipelineOption] function to be used with [NewPipeline].
+func WithDefaultHeaders(headers http.Header) PipelineOption {
+	return func(p *Pipeline) error {
+		if p.defaultHeaders == nil {
+			p.defaultHeaders = http.Header{}
+		}
+		for key, values := range headers {
+			p.defaultHeaders[http.CanonicalHeaderKey(key)] = append([]string(nil), values...)
+		}
+		return nil
+	}
+}
+
+// WithUserAgent adds a product token to the default [HeaderUserAgent] of the pipeline.
+//
+// Each call appends a "product/version" token (or just "product" when version is
+// empty), so the final header reads from the first option to the last. Like
+// [WithDefaultHeaders], it is only applied when the request has no User-Agent.
+//
+// Example:
+//
+//	pipeline, err := NewPipeline(
+//	    WithUserAgent("my-app", "1.4.0"),
+//	    WithUserAgent("choco-go", ""),
+//	)
+//	// User-Agent: my-app/1.4.0 choco-go
+//
+// Parameters:
+//   - product: The product name; must not be empty or contain spaces.
+//   - version: The optional product version.
+//
+// Returns:
+//   - A [PipelineOption] function to be used with [NewPipeline].
+func WithUserAgent(product, version string) PipelineOption {
+	return func(p *Pipeline) error {
+		if product == "" || strings.ContainsAny(product+version, " /\t") {
+			return NewError("pipeline: invalid user agent product %q/%q", product, version)
+		}
+		token := product
+		if version != "" {
+			token += "/" + version
+		}
+		if p.defaultHeaders == nil {
+			p.defaultHeaders = http.Header{}
+		}
+		if ua := p.defaultHeaders.Get(HeaderUserAgent); ua != "" {
+			token = ua + " " + token
+		}
+		p.defaultHeaders.Set(HeaderUserAgent, token)
+		return nil
+	}
+}
+
+// WithCodecs registers one or more [Codec]s on the pipeline.
+//
+// The pipeline starts with the JSON, XML, text and form codecs (see [DefaultCodecs]).
+// A codec handling the same content types as a registered one replaces it; other
+// codecs are added after the defaults.
+//
+// Codecs encode the values passed to [Request.SetBodyAs] and decode responses
+// in [Pipeline.DecodeResponse].
+//
+// Example:
+//
+//	pipeline, err := NewPipeline(
+//	    WithCodecs(msgpackCodec{}),
+//	)
+//
+// Parameters:
+//   - codecs: One or more [Codec] implementations.
+//
+// Returns:
+//   - A [PipelineOption] function to be used with [NewPipeline].
+func WithCodecs(codecs ...Codec) PipelineOption {
+	return func(p *Pipeline) error {
+		if p.codecs == nil {
+			p.codecs = DefaultCodecs()
+		} else {
+			p.codecs = p.codecs.Clone()
+		}
+		for _, c := range codecs {
+			if c == nil || len(c.ContentTypes()) == 0 {
+				return NewError("pipeline: codec %T has no content type", c)
+			}
+			p.codecs.Register(c)
+		}
+		return nil
+	}
+}
+
+// WithObserver registers an [Observer] notified at fixed points of every execution
+// of the pipeline: before the first step, around each transport call, on retries
+// and at the end.
+//
+// Unlike a [PipelineStep], an observer sees the same events wherever the steps are
+// placed, and cannot change or interrupt the execution. Calling this option more
+// than once registers several observers, notified in order.
+//
+// Example:
+//
+//	pipeline, err := NewPipeline(
+//	    WithObserver(Observer{
+//	        OnResponse: func(ev HookEvent) {
+//	            log.Printf("%s %s -> %d in %s", ev.Method, ev.URL, ev.StatusCode, ev.Elapsed)
+//	        },
+//	        OnRetry: func(ev HookEvent) {
+//	            log.Printf("retrying %s %s (attempt %d)", ev.Method, ev.URL, ev.Attempt)
+//	        },
+//	    }),
+//	)
+//
+// Parameters:
+//   - o: The observer to register.
+//
+// Returns:
+//   - A [PipelineOption] function to be used with [NewPipeline].
+func WithObserver(o Observer) PipelineOption {
+	return func(p *Pipeline) error {
+		p.observers = append(p.observers, o)
+		return nil
+	}
+}
+
+// WithSchemeTransport registers the transport sending requests whose URL has the given scheme.
+//
+// Requests with an http or https URL use the pipeline transport (see [WithCustomTransport])
+// unless a transport is registered for these schemes too. Requests with any other scheme fail
+// unless the pipeline transport supports it (see [SchemeTransport]).
+//
+// Example:
+//
+//	pipeline, err := NewPipeline(
+//	    WithSchemeTransport("unix", NewUnixTransport(nil)),
+//	    WithSchemeTransport("mock", HandlerTransport(fixtures)),
+//	)
+//	req, err := NewRequest(ctx, "GET", "mock://users/42")
+//
+// Parameters:
+//   - scheme: The URL scheme, such as "unix" or "mock". Schemes are case-insensitive.
+//   - tr: The transport for that scheme.
+//
+// Returns:
+//   - A [PipelineOption] function to be used with [NewPipeline].
+func WithSchemeTransport(scheme string, tr Transport) PipelineOption {
+	return func(p *Pipeline) error {
+		if scheme == "" || tr == nil {
+			return NewError("pipeline: scheme transport needs a scheme and a transport")
+		}
+		if p.schemes == nil {
+			p.schemes = map[string]Transport{}
+		}
+		p.schemes[strings.ToLower(scheme)] = tr
+		return nil
+	}
+}
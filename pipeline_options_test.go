@@ -0,0 +1,240 @@
+package choco
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"nyxze/choco-go/chocotest"
+)
+
+// lastRequest returns the last request received by tr.
+func lastRequest(t *testing.T, tr *chocotest.Transport) *http.Request {
+	t.Helper()
+	calls := tr.Calls()
+	if len(calls) == 0 {
+		t.Fatal("transport received no request")
+	}
+	return calls[len(calls)-1].Request
+}
+
+func TestPipelineOptionError(t *testing.T) {
+	errOption := errors.New("bad option")
+	failing := func(*Pipeline) error { return errOption }
+	if _, err := NewPipeline(failing); !errors.Is(err, errOption) {
+		t.Errorf("NewPipeline() err = %v, want wrapping the option error", err)
+	}
+	p, err := NewPipeline()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.With(failing); !errors.Is(err, errOption) {
+		t.Errorf("With() err = %v, want wrapping the option error", err)
+	}
+}
+
+func TestWithBaseURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		base     string
+		endpoint string
+		want     string
+	}{
+		{"absolute path", "https://api.example.com/v1", "/users?page=2", "https://api.example.com/v1/users?page=2"},
+		{"relative path", "https://api.example.com/v1/", "users", "https://api.example.com/v1/users"},
+		{"root base", "https://api.example.com", "/users/a%2Fb", "https://api.example.com/users/a%2Fb"},
+		{"absolute endpoint", "https://api.example.com/v1", "http://other.example.com/x", "http://other.example.com/x"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := pingTransport()
+			p, err := NewPipeline(WithCustomTransport(tr), WithBaseURL(tt.base))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req, err := NewRequest(context.Background(), "GET", tt.endpoint)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := p.Execute(req); err != nil {
+				t.Fatal(err)
+			}
+			if got := lastRequest(t, tr).URL.String(); got != tt.want {
+				t.Errorf("URL = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := NewPipeline(WithBaseURL("/relative")); err == nil {
+		t.Error("expected error for base URL without host")
+	}
+}
+
+func TestWithDefaultHeaders(t *testing.T) {
+	tr := pingTransport()
+	var seen string
+	p, err := NewPipeline(
+		WithCustomTransport(tr),
+		WithDefaultHeaders(http.Header{"x-app": {"choco"}, HeaderAccept: {ContentTypeAppJSON}}),
+		WithUserAgent("my-app", "1.4.0"),
+		WithUserAgent("choco-go", ""),
+		WithStepFuncs(func(req *Request, next RequestHandlerFunc) (*http.Response, error) {
+			seen = req.Raw().Header.Get("X-App")
+			return next(req)
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := NewRequest(context.Background(), "GET", testURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetAccept(ContentTypeAppXML)
+	if _, err := p.Execute(req); err != nil {
+		t.Fatal(err)
+	}
+
+	if seen != "choco" {
+		t.Errorf("default header not visible to first step, got %q", seen)
+	}
+	h := lastRequest(t, tr).Header
+	if got := h.Get(HeaderAccept); got != ContentTypeAppXML {
+		t.Errorf("Accept = %q, request value should win", got)
+	}
+	if got, want := h.Get(HeaderUserAgent), "my-app/1.4.0 choco-go"; got != want {
+		t.Errorf("User-Agent = %q, want %q", got, want)
+	}
+
+	if _, err := NewPipeline(WithUserAgent("bad product", "")); err == nil {
+		t.Error("expected error for product with spaces")
+	}
+}
+
+func TestPipelineWith(t *testing.T) {
+	tr := pingTransport()
+	base, err := NewPipeline(
+		WithCustomTransport(tr),
+		WithSteps(Named("logging", tagStep("logging")), tagStep("a"), tagStep("b")),
+		WithDefaultHeaders(http.Header{"X-Base": {"1"}}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Derive twice from the same base: appends must not alias each other.
+	tenant1, err := base.With(WithSteps(tagStep("tenant1")), WithDefaultHeaders(http.Header{"X-Tenant": {"1"}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tenant2, err := base.With(WithSteps(tagStep("tenant2")), InsertAfter("logging", tagStep("tracing")), WithCodecs(upperCodec{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(p Pipeline) *http.Request {
+		t.Helper()
+		req, err := NewRequest(context.Background(), "GET", testURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := p.Execute(req); err != nil {
+			t.Fatal(err)
+		}
+		return lastRequest(t, tr)
+	}
+	tests := []struct {
+		name     string
+		p        Pipeline
+		steps    string
+		tenantHd string
+	}{
+		{"base", base, "logging,a,b", ""},
+		{"tenant1", tenant1, "logging,a,b,tenant1", "1"},
+		{"tenant2", tenant2, "logging,tracing,a,b,tenant2", ""},
+	}
+	for _, tt := range tests {
+		got := run(tt.p)
+		if steps := strings.Join(got.Header.Values("X-Steps"), ","); steps != tt.steps {
+			t.Errorf("%s: steps = %q, want %q", tt.name, steps, tt.steps)
+		}
+		if h := got.Header.Get("X-Tenant"); h != tt.tenantHd || got.Header.Get("X-Base") != "1" {
+			t.Errorf("%s: X-Tenant = %q, X-Base = %q", tt.name, h, got.Header.Get("X-Base"))
+		}
+	}
+	if _, ok := base.codecs.Lookup("text/x-upper"); ok {
+		t.Error("With modified the codecs of the base pipeline")
+	}
+	if len(tr.Calls()) != 3 {
+		t.Errorf("derived pipelines do not share the transport: %d calls", len(tr.Calls()))
+	}
+
+	if _, err := base.With(InsertBefore("missing", tagStep("x"))); err == nil {
+		t.Error("expected error from With")
+	}
+}
+
+func TestWithSchemeTransport(t *testing.T) {
+	mock := chocotest.NewTransport()
+	mock.On("GET", "/42").Respond(chocotest.Text(http.StatusOK, "user 42"))
+	httpTr := pingTransport()
+	p, err := NewPipeline(
+		WithCustomTransport(httpTr),
+		WithSchemeTransport("MOCK", mock),
+		WithSchemeTransport("fixture", HandlerTransport(http.NotFoundHandler())),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		url    string
+		status int
+		err    string
+	}{
+		{"mock://users/42", http.StatusOK, ""},
+		{"fixture:///data.json", http.StatusNotFound, ""},
+		{"https://www.example.com/", http.StatusOK, ""},
+		{"ftp://www.example.com/file", 0, `unsupported protocol scheme "ftp"`},
+	}
+	for _, tt := range tests {
+		req, err := NewRequest(context.Background(), "GET", tt.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := p.Execute(req)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%s: err = %v, want %q", tt.url, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || resp.StatusCode != tt.status {
+			t.Errorf("%s: resp = %v, err = %v; want status %d", tt.url, resp, err, tt.status)
+		}
+	}
+	if len(mock.Calls()) != 1 || len(httpTr.Calls()) != 1 {
+		t.Errorf("calls: mock %d, http %d; want 1 each", len(mock.Calls()), len(httpTr.Calls()))
+	}
+
+	// http can be overridden too.
+	override, err := p.With(WithSchemeTransport("http", mock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := NewRequest(context.Background(), "GET", "http://www.example.com/42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := override.Execute(req); err != nil || len(mock.Calls()) != 2 {
+		t.Errorf("http override: err = %v, mock calls = %d", err, len(mock.Calls()))
+	}
+	if _, ok := p.schemes["http"]; ok {
+		t.Error("With modified the schemes of the base pipeline")
+	}
+	if _, err := NewPipeline(WithSchemeTransport("", mock)); err == nil {
+		t.Error("expected error for an empty scheme")
+	}
+}
@@ -0,0 +1,288 @@
+// Package progress provides a [choco.PipelineStep] reporting upload and
+// download progress of request and response bodies.
+//
+//	step := progress.NewStep(
+//	    progress.WithCallback(func(p progress.Progress) {
+//	        fmt.Printf("\r%s %d/%d bytes, ETA %s", p.Direction, p.Bytes, p.Total, p.ETA)
+//	    }),
+//	    progress.WithInterval(500*time.Millisecond),
+//	)
+//	pipeline, err := choco.NewPipeline(choco.WithSteps(step))
+package progress
+
+import (
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"nyxze/choco-go"
+)
+
+// DefaultInterval is the minimum time between two reports when no
+// [WithInterval] option is given.
+const DefaultInterval = 200 * time.Millisecond
+
+// Direction tells whether a [Progress] report is about the request or the response body.
+type Direction int
+
+const (
+	Upload Direction = iota
+	Download
+)
+
+// String returns "upload" or "download".
+func (d Direction) String() string {
+	if d == Upload {
+		return "upload"
+	}
+	return "download"
+}
+
+// Progress is a snapshot of a body transfer.
+type Progress struct {
+	Direction Direction
+	Method    string
+	URL       string
+	// Bytes transferred so far.
+	Bytes int64
+	// Total body size, or -1 when unknown.
+	Total int64
+	// Rate is the average transfer rate in bytes per second.
+	Rate float64
+	// ETA is the estimated time left, or -1 when it cannot be estimated.
+	ETA time.Duration
+	// Elapsed is the time since the transfer started.
+	Elapsed time.Duration
+	// Done is set on the last report of a transfer.
+	Done bool
+}
+
+// Option configures a [Step].
+type Option func(*Step)
+
+// WithCallback adds a function called with every report. Calls are made
+// from the goroutine reading the body and should return quickly.
+func WithCallback(fn func(Progress)) Option {
+	return func(s *Step) {
+		s.callbacks = append(s.callbacks, fn)
+	}
+}
+
+// WithChannel sends every report to ch. Sends never block the transfer:
+// reports, including the final one, are dropped when ch is full, so give it
+// room for the reports that must not be missed.
+func WithChannel(ch chan<- Progress) Option {
+	return WithCallback(func(p Progress) {
+		select {
+		case ch <- p:
+		default:
+		}
+	})
+}
+
+// WithInterval sets the minimum time between two reports of a transfer.
+// The final report is always sent.
+func WithInterval(d time.Duration) Option {
+	return func(s *Step) {
+		s.interval = d
+	}
+}
+
+// WithDirections restricts reporting to the given directions. Both are reported by default.
+func WithDirections(dirs ...Direction) Option {
+	return func(s *Step) {
+		s.upload, s.download = false, false
+		for _, d := range dirs {
+			switch d {
+			case Upload:
+				s.upload = true
+			case Download:
+				s.download = true
+			}
+		}
+	}
+}
+
+// Step is a [choco.PipelineStep] wrapping request and response bodies with
+// byte counters. It is safe for concurrent use.
+type Step struct {
+	callbacks []func(Progress)
+	interval  time.Duration
+	upload    bool
+	download  bool
+}
+
+// NewStep creates a progress [Step].
+func NewStep(opts ...Option) *Step {
+	s := &Step{interval: DefaultInterval, upload: true, download: true}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Name implements [choco.NamedStep].
+func (s *Step) Name() string {
+	return "progress"
+}
+
+// Do implements [choco.PipelineStep].
+//
+// When the request body is rewound, through GetBody or by seeking back the
+// wrapped body or [choco.Request.Body], the upload counter follows the new
+// position, so retries report correctly.
+func (s *Step) Do(req *choco.Request, next choco.RequestHandlerFunc) (*http.Response, error) {
+	raw := req.Raw()
+	method, url := raw.Method, raw.URL.String()
+
+	if s.upload && raw.Body != nil && raw.Body != http.NoBody {
+		origBody, origGetBody := raw.Body, raw.GetBody
+		defer func() {
+			raw.Body, raw.GetBody = origBody, origGetBody
+		}()
+
+		up := s.tracker(Upload, method, url, raw.ContentLength)
+		raw.Body = up.wrap(origBody)
+		if origGetBody != nil {
+			raw.GetBody = func() (io.ReadCloser, error) {
+				rc, err := origGetBody()
+				if err != nil {
+					return nil, err
+				}
+				up.reset()
+				return up.wrap(rc), nil
+			}
+		}
+	}
+
+	resp, err := next(req)
+	if err != nil || resp == nil || resp.Body == nil || !s.download {
+		return resp, err
+	}
+	down := s.tracker(Download, method, url, resp.ContentLength)
+	resp.Body = down.wrap(resp.Body)
+	return resp, nil
+}
+
+func (s *Step) tracker(dir Direction, method, url string, total int64) *tracker {
+	if total <= 0 {
+		total = -1
+	}
+	return &tracker{
+		step:  s,
+		start: time.Now(),
+		progress: Progress{
+			Direction: dir,
+			Method:    method,
+			URL:       url,
+			Total:     total,
+		},
+	}
+}
+
+// tracker counts the bytes of one transfer.
+type tracker struct {
+	step *Step
+
+	mu       sync.Mutex
+	start    time.Time
+	last     time.Time
+	done     bool
+	progress Progress
+}
+
+func (t *tracker) wrap(rc io.ReadCloser) io.ReadCloser {
+	c := &counter{ReadCloser: rc, t: t}
+	if _, ok := rc.(io.Seeker); ok {
+		return &seekCounter{c}
+	}
+	return c
+}
+
+func (t *tracker) reset() {
+	t.mu.Lock()
+	t.progress.Bytes = 0
+	t.start = time.Now()
+	t.last = time.Time{}
+	t.done = false
+	t.mu.Unlock()
+}
+
+// set updates the byte count and reports it if the interval has elapsed.
+func (t *tracker) set(bytes int64, done bool) {
+	t.mu.Lock()
+	if t.done {
+		t.mu.Unlock()
+		return
+	}
+	now := time.Now()
+	t.progress.Bytes = bytes
+	if !done && now.Sub(t.last) < t.step.interval {
+		t.mu.Unlock()
+		return
+	}
+	t.last = now
+	t.done = done
+
+	p := t.progress
+	p.Done = done
+	p.Elapsed = now.Sub(t.start)
+	p.ETA = -1
+	if secs := p.Elapsed.Seconds(); secs > 0 {
+		p.Rate = float64(p.Bytes) / secs
+	}
+	if done {
+		p.ETA = 0
+	} else if p.Total > 0 && p.Rate > 0 {
+		p.ETA = time.Duration(float64(p.Total-p.Bytes) / p.Rate * float64(time.Second))
+	}
+	t.mu.Unlock()
+
+	for _, fn := range t.step.callbacks {
+		fn(p)
+	}
+}
+
+// counter reports the bytes read through it.
+type counter struct {
+	io.ReadCloser
+	t   *tracker
+	pos int64
+}
+
+func (c *counter) Read(p []byte) (int, error) {
+	// The body may be rewound without going through the counter, e.g. with
+	// Request.Body().Seek: follow the position of the underlying body.
+	if s, ok := c.ReadCloser.(io.Seeker); ok {
+		if pos, err := s.Seek(0, io.SeekCurrent); err == nil {
+			c.moveTo(pos)
+		}
+	}
+	n, err := c.ReadCloser.Read(p)
+	c.pos += int64(n)
+	c.t.set(c.pos, err == io.EOF)
+	return n, err
+}
+
+// moveTo sets the position of the counter, restarting the transfer when it goes back.
+func (c *counter) moveTo(pos int64) {
+	if pos < c.pos {
+		c.t.reset()
+	}
+	c.pos = pos
+}
+
+// seekCounter is a counter that follows seeks on the underlying body.
+type seekCounter struct {
+	*counter
+}
+
+func (c *seekCounter) Seek(offset int64, whence int) (int64, error) {
+	pos, err := c.ReadCloser.(io.Seeker).Seek(offset, whence)
+	if err != nil {
+		return pos, err
+	}
+	c.moveTo(pos)
+	return pos, nil
+}
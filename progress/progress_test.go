@@ -0,0 +1,234 @@
+package progress_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"nyxze/choco-go"
+	"nyxze/choco-go/chocotest"
+	"nyxze/choco-go/progress"
+)
+
+func TestStepReportsUploadAndDownload(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		reports []progress.Progress
+	)
+	step := progress.NewStep(
+		progress.WithInterval(0),
+		progress.WithCallback(func(p progress.Progress) {
+			mu.Lock()
+			reports = append(reports, p)
+			mu.Unlock()
+		}),
+	)
+
+	tr := chocotest.NewTransport()
+	tr.On("PUT", "/file").Respond(chocotest.Text(http.StatusOK, strings.Repeat("d", 300)))
+
+	// A step after the progress step retries the upload once, rewinding the body.
+	retry := choco.PipelineStepFunc(func(req *choco.Request, next choco.RequestHandlerFunc) (*http.Response, error) {
+		raw := req.Raw()
+		io.CopyN(io.Discard, raw.Body, 50)
+		body, err := raw.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		raw.Body = body
+		return next(req)
+	})
+
+	p, err := choco.NewPipeline(choco.WithCustomTransport(tr), choco.WithSteps(step, retry))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := choco.NewRequest(context.Background(), "PUT", "http://www.example.com/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := req.SetBody(choco.NopCloser(strings.NewReader(strings.Repeat("u", 100))), choco.ContentTypeTextPlain); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := p.Execute(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	var up, down []progress.Progress
+	for _, r := range reports {
+		if r.Direction == progress.Upload {
+			up = append(up, r)
+		} else {
+			down = append(down, r)
+		}
+	}
+	if len(up) == 0 || len(down) == 0 {
+		t.Fatalf("got %d upload and %d download reports", len(up), len(down))
+	}
+
+	lastUp := up[len(up)-1]
+	if !lastUp.Done || lastUp.Bytes != 100 || lastUp.Total != 100 {
+		t.Errorf("final upload report = %+v, want 100/100 after the rewind", lastUp)
+	}
+	lastDown := down[len(down)-1]
+	if !lastDown.Done || lastDown.Bytes != 300 || lastDown.Total != 300 || lastDown.ETA != 0 {
+		t.Errorf("final download report = %+v", lastDown)
+	}
+	for i := 1; i < len(up); i++ {
+		if up[i].Done && up[i].Bytes > 100 {
+			t.Errorf("upload counter not reset on rewind: %+v", up[i])
+		}
+	}
+
+	// The original body must be restored on the request.
+	if _, ok := req.Raw().Body.(io.Seeker); !ok {
+		t.Error("request body was not restored after the step")
+	}
+}
+
+func TestStepResetsOnRequestBodySeek(t *testing.T) {
+	var (
+		mu sync.Mutex
+		up []progress.Progress
+	)
+	step := progress.NewStep(
+		progress.WithInterval(0),
+		progress.WithDirections(progress.Upload),
+		progress.WithCallback(func(p progress.Progress) {
+			mu.Lock()
+			up = append(up, p)
+			mu.Unlock()
+		}),
+	)
+
+	tr := chocotest.NewTransport()
+	tr.On("PUT", "/file").Respond(chocotest.Text(http.StatusOK, "ok"))
+
+	// The first attempt sends the whole body, then the body is rewound through
+	// the choco request, bypassing the wrapper installed by the progress step.
+	retry := choco.PipelineStepFunc(func(req *choco.Request, next choco.RequestHandlerFunc) (*http.Response, error) {
+		io.Copy(io.Discard, req.Raw().Body)
+		if _, err := req.Body().Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		return next(req)
+	})
+
+	p, err := choco.NewPipeline(choco.WithCustomTransport(tr), choco.WithSteps(step, retry))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := choco.NewRequest(context.Background(), "PUT", "http://www.example.com/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := req.SetBody(choco.NopCloser(strings.NewReader(strings.Repeat("u", 100))), choco.ContentTypeTextPlain); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := p.Execute(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	done := 0
+	for _, r := range up {
+		if r.Bytes > 100 {
+			t.Errorf("upload counter not reset on rewind: %+v", r)
+		}
+		if r.Done {
+			done++
+		}
+	}
+	if done != 2 {
+		t.Errorf("got %d final upload reports, want one per attempt: %+v", done, up)
+	}
+	if last := up[len(up)-1]; !last.Done || last.Bytes != 100 {
+		t.Errorf("final upload report = %+v, want 100/100", last)
+	}
+}
+
+func TestStepChannelThrottle(t *testing.T) {
+	ch := make(chan progress.Progress, 100)
+	step := progress.NewStep(progress.WithChannel(ch), progress.WithDirections(progress.Download))
+
+	tr := chocotest.NewTransport()
+	tr.On("GET", "").Respond(chocotest.Text(http.StatusOK, strings.Repeat("d", 4096)))
+	p, err := choco.NewPipeline(choco.WithCustomTransport(tr), choco.WithSteps(step))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := choco.NewRequest(context.Background(), "GET", "http://www.example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := p.Execute(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 16)
+	for {
+		if _, err := resp.Body.Read(buf); err != nil {
+			break
+		}
+	}
+	close(ch)
+
+	var n int
+	var last progress.Progress
+	for r := range ch {
+		n++
+		last = r
+	}
+	// 256 reads within the default interval are throttled down to the first and final reports.
+	if n > 3 || !last.Done || last.Bytes != 4096 {
+		t.Errorf("got %d reports, last %+v", n, last)
+	}
+}
+
+func TestStepChannelDrainedAfterExecute(t *testing.T) {
+	ch := make(chan progress.Progress, 1)
+	step := progress.NewStep(progress.WithChannel(ch), progress.WithInterval(0), progress.WithDirections(progress.Upload))
+
+	tr := chocotest.NewTransport()
+	tr.On("PUT", "").Respond(chocotest.Status(http.StatusOK))
+	p, err := choco.NewPipeline(choco.WithCustomTransport(tr), choco.WithSteps(step))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := choco.NewRequest(context.Background(), "PUT", "http://www.example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := req.SetBody(choco.NopCloser(strings.NewReader(strings.Repeat("u", 4096))), choco.ContentTypeTextPlain); err != nil {
+		t.Fatal(err)
+	}
+
+	// The channel is only drained once Execute returns: reports must not block the upload.
+	done := make(chan error, 1)
+	go func() {
+		resp, err := p.Execute(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Execute blocked on a full progress channel")
+	}
+	if r := <-ch; r.Direction != progress.Upload {
+		t.Errorf("report = %+v", r)
+	}
+}
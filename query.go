@@ -0,0 +1,292 @@
+package choco
+
+import (
+	"encoding"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SliceFormat controls how slice and array fields are encoded by [Request.SetQueryStruct].
+type SliceFormat int
+
+const (
+	// SliceRepeated encodes each element as its own key: ids=1&ids=2
+	SliceRepeated SliceFormat = iota
+	// SliceComma joins elements with a comma: ids=1,2
+	SliceComma
+	// SliceBrackets appends [] to the key for each element: ids[]=1&ids[]=2
+	SliceBrackets
+)
+
+// SetQuery sets the query parameter key to value, replacing any existing values.
+func (r *Request) SetQuery(key, value string) {
+	q := r.req.URL.Query()
+	q.Set(key, value)
+	r.req.URL.RawQuery = q.Encode()
+}
+
+// AddQuery appends value to the query parameter key.
+func (r *Request) AddQuery(key, value string) {
+	q := r.req.URL.Query()
+	q.Add(key, value)
+	r.req.URL.RawQuery = q.Encode()
+}
+
+// DelQuery removes every value of the query parameter key.
+func (r *Request) DelQuery(key string) {
+	q := r.req.URL.Query()
+	q.Del(key)
+	r.req.URL.RawQuery = q.Encode()
+}
+
+// SetQueryStruct encodes the exported fields of v as query parameters.
+// Keys already present on the request are replaced by the encoded values.
+//
+// Fields are named with the `query` struct tag, which follows the encoding/json
+// conventions ("-" skips the field, ",omitempty" skips zero values). Slice format
+// and time layout can be selected with extra tag options:
+//
+//	type Search struct {
+//	    Term   string    `query:"q"`
+//	    Tags   []string  `query:"tag,comma"`     // tag=a,b
+//	    IDs    []int     `query:"id,brackets"`   // id[]=1&id[]=2
+//	    Since  time.Time `query:"since,omitempty"` // RFC 3339
+//	    Before time.Time `query:"before,unix"`   // seconds since epoch
+//	    Limit  *int      `query:"limit"`         // nil pointers are skipped
+//	}
+func (r *Request) SetQueryStruct(v any) error {
+	values, err := encodeQuery(v)
+	if err != nil {
+		return err
+	}
+	q := r.req.URL.Query()
+	for k, vs := range values {
+		q[k] = vs
+	}
+	r.req.URL.RawQuery = q.Encode()
+	return nil
+}
+
+// queryField describes how a single struct field is encoded.
+type queryField struct {
+	index     []int
+	name      string
+	omitEmpty bool
+	unix      bool
+	format    SliceFormat
+}
+
+// queryFieldCache maps a struct reflect.Type to its []queryField.
+var queryFieldCache sync.Map
+
+var (
+	timeType          = reflect.TypeFor[time.Time]()
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+)
+
+func encodeQuery(v any) (url.Values, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil, NewError("query: cannot encode nil %T", v)
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, NewError("query: expected struct, got %T", v)
+	}
+
+	values := url.Values{}
+	for _, f := range cachedQueryFields(rv.Type()) {
+		fv, ok := fieldByIndex(rv, f.index)
+		if !ok {
+			continue
+		}
+		if err := encodeQueryField(values, f, fv); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+func encodeQueryField(values url.Values, f queryField, fv reflect.Value) error {
+	// Like encoding/json, omitempty keeps non-nil pointers to zero values.
+	if f.omitEmpty && fv.IsZero() {
+		return nil
+	}
+	fv, ok := derefValue(fv)
+	if !ok {
+		return nil
+	}
+
+	if isScalar(fv) {
+		s, err := formatQueryValue(fv, f.unix)
+		if err != nil {
+			return NewError("query: field %q: %v", f.name, err)
+		}
+		values.Set(f.name, s)
+		return nil
+	}
+
+	if fv.Kind() != reflect.Slice && fv.Kind() != reflect.Array {
+		return NewError("query: field %q: unsupported type %s", f.name, fv.Type())
+	}
+
+	elems := make([]string, 0, fv.Len())
+	for i := range fv.Len() {
+		ev, ok := derefValue(fv.Index(i))
+		if !ok {
+			continue
+		}
+		s, err := formatQueryValue(ev, f.unix)
+		if err != nil {
+			return NewError("query: field %q: %v", f.name, err)
+		}
+		elems = append(elems, s)
+	}
+	if len(elems) == 0 {
+		return nil
+	}
+
+	switch f.format {
+	case SliceComma:
+		values.Set(f.name, strings.Join(elems, ","))
+	case SliceBrackets:
+		values[f.name+"[]"] = elems
+	default:
+		values[f.name] = elems
+	}
+	return nil
+}
+
+// isScalar reports whether v encodes to a single query value.
+func isScalar(v reflect.Value) bool {
+	if v.Type() == timeType || v.Type().Implements(textMarshalerType) {
+		return true
+	}
+	if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+		return true
+	}
+	return v.Kind() != reflect.Slice && v.Kind() != reflect.Array
+}
+
+func formatQueryValue(v reflect.Value, unix bool) (string, error) {
+	if v.Type() == timeType {
+		t := v.Interface().(time.Time)
+		if unix {
+			return strconv.FormatInt(t.Unix(), 10), nil
+		}
+		return t.Format(time.RFC3339), nil
+	}
+	if v.Type().Implements(textMarshalerType) {
+		b, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		return string(b), err
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 32), nil
+	case reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64), nil
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return string(v.Bytes()), nil
+		}
+	}
+	return "", NewError("unsupported type %s", v.Type())
+}
+
+// derefValue follows pointers and interfaces, reporting false when a nil is hit.
+func derefValue(v reflect.Value) (reflect.Value, bool) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return v, false
+		}
+		v = v.Elem()
+	}
+	return v, true
+}
+
+// fieldByIndex is like [reflect.Value.FieldByIndex] but reports false
+// instead of panicking when an embedded pointer is nil.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return v, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+func cachedQueryFields(t reflect.Type) []queryField {
+	if f, ok := queryFieldCache.Load(t); ok {
+		return f.([]queryField)
+	}
+	f, _ := queryFieldCache.LoadOrStore(t, typeQueryFields(t, nil))
+	return f.([]queryField)
+}
+
+func typeQueryFields(t reflect.Type, parent []int) []queryField {
+	var fields []queryField
+	for i := range t.NumField() {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("query")
+		if tag == "-" {
+			continue
+		}
+		index := append(append([]int(nil), parent...), i)
+
+		// Flatten untagged embedded structs.
+		if sf.Anonymous && tag == "" {
+			ft := sf.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct && ft != timeType {
+				fields = append(fields, typeQueryFields(ft, index)...)
+				continue
+			}
+		}
+		if !sf.IsExported() {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = sf.Name
+		}
+		f := queryField{index: index, name: name}
+		for opt := range strings.SplitSeq(opts, ",") {
+			switch opt {
+			case "omitempty":
+				f.omitEmpty = true
+			case "unix":
+				f.unix = true
+			case "comma":
+				f.format = SliceComma
+			case "brackets":
+				f.format = SliceBrackets
+			case "repeated":
+				f.format = SliceRepeated
+			}
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
@@ -0,0 +1,83 @@
+package choco
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type queryEmbedded struct {
+	Page int `query:"page"`
+}
+
+type querySearch struct {
+	queryEmbedded
+	Term    string    `query:"q"`
+	Tags    []string  `query:"tag"`
+	IDs     []int     `query:"id,comma"`
+	Sorts   []string  `query:"sort,brackets"`
+	Since   time.Time `query:"since,omitempty"`
+	Before  time.Time `query:"before,unix"`
+	Limit   *int      `query:"limit"`
+	Offset  *int      `query:"offset"`
+	Count   *int      `query:"count,omitempty"`
+	Skip    string    `query:"-"`
+	Empty   string    `query:"empty,omitempty"`
+	private string
+}
+
+func TestRequestQuery(t *testing.T) {
+	req, err := NewRequest(context.Background(), "GET", testURL+"?a=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetQuery("b", "2")
+	req.AddQuery("b", "3")
+	req.DelQuery("a")
+	if got, want := req.Raw().URL.RawQuery, "b=2&b=3"; got != want {
+		t.Fatalf("RawQuery = %q, want %q", got, want)
+	}
+}
+
+func TestSetQueryStruct(t *testing.T) {
+	req, err := NewRequest(context.Background(), "GET", testURL+"?q=old&keep=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	limit, zero := 10, 0
+	v := querySearch{
+		queryEmbedded: queryEmbedded{Page: 2},
+		Term:          "choco bo",
+		Tags:          []string{"a", "b"},
+		IDs:           []int{1, 2},
+		Sorts:         []string{"name"},
+		Before:        time.Unix(1700000000, 0),
+		Limit:         &limit,
+		Count:         &zero,
+		Skip:          "skip",
+		private:       "x",
+	}
+	if err := req.SetQueryStruct(&v); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "before=1700000000&count=0&id=1%2C2&keep=1&limit=10&page=2&q=choco+bo&sort%5B%5D=name&tag=a&tag=b"
+	if got := req.Raw().URL.RawQuery; got != want {
+		t.Errorf("RawQuery =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestSetQueryStructErrors(t *testing.T) {
+	req, err := NewRequest(context.Background(), "GET", testURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := req.SetQueryStruct(42); err == nil {
+		t.Error("expected error for non-struct value")
+	}
+	if err := req.SetQueryStruct(struct {
+		M map[string]string `query:"m"`
+	}{M: map[string]string{}}); err == nil {
+		t.Error("expected error for unsupported field type")
+	}
+}
@@ -1,10 +1,13 @@
 package choco
 
 import (
+	"bytes"
 	"context"
 	"io"
 	"net/http"
 	"net/http/httputil"
+	"os"
+	"slices"
 )
 
 // Request wraps the standard http.Request.
@@ -14,6 +17,16 @@ type Request struct {
 
 	// Content of the request
 	body io.ReadSeekCloser
+
+	// Non-seekable content set by SetStreamBody
+	stream io.ReadCloser
+
+	// Value set by SetBodyAs, encoded by the pipeline codecs on Execute
+	bodyValue *any
+
+	// Per-request steps set by AddSteps and SkipSteps
+	extraSteps   []PipelineStep
+	skippedSteps []string
 }
 
 // RequestHandlerFunc defines a function that processes a *Request
@@ -34,6 +47,16 @@ func (r *Request) Body() io.ReadSeekCloser {
 	return r.body
 }
 
+// WithContext returns a shallow copy of the request with its context changed
+// to ctx. Unlike [Request.Clone], the copy shares headers, URL and body with
+// the original; it is meant for steps that need to scope the rest of the
+// pipeline to a derived context (timeouts, cancellation).
+func (r *Request) WithContext(ctx context.Context) *Request {
+	c := *r
+	c.req = r.req.WithContext(ctx)
+	return &c
+}
+
 // Return the underlying [http.Request]
 func (r *Request) Raw() *http.Request {
 	return r.req
@@ -41,6 +64,9 @@ func (r *Request) Raw() *http.Request {
 
 // Close the body associated to this request
 func (r *Request) Close() error {
+	if r.stream != nil {
+		return r.stream.Close()
+	}
 	if r.body == nil {
 		return nil
 	}
@@ -83,15 +109,117 @@ func (r *Request) SetBody(body io.ReadSeekCloser, contentType string) error {
 		r.SetContentType(contentType)
 	}
 	r.body = body
+	r.stream = nil
+	r.bodyValue = nil
 	return nil
 }
 
+// DumpRequest returns the request as sent on the wire, see [httputil.DumpRequestOut].
+// A value set with [Request.SetBodyAs] is encoded with the default codecs.
 func (r *Request) DumpRequest(body bool) ([]byte, error) {
 	if r.req == nil {
 		return nil, NewError("request: missing inner *http.Request")
 	}
+	r, err := r.withEncodedBody()
+	if err != nil {
+		return nil, err
+	}
 	if r.req.GetBody != nil {
 		r.req.Body, _ = r.req.GetBody()
 	}
 	return httputil.DumpRequestOut(r.req, body)
 }
+
+// Clone returns a deep copy of the request with its context changed to ctx.
+// Headers, URL and per-request values are copied, so the clone can be mutated
+// independently.
+//
+// The clone gets its own read position on the body: when the body supports
+// [io.ReaderAt] (e.g. [bytes.Reader], [strings.Reader] or [os.File]) it is
+// shared without copying, otherwise it is read into memory once. Clones can
+// then be sent concurrently. Closing a clone does not close the original body.
+func (r *Request) Clone(ctx context.Context) (*Request, error) {
+	if r.req == nil {
+		return nil, NewError("request: missing inner *http.Request")
+	}
+	if r.stream != nil {
+		return nil, NewError("request: cannot clone a streaming body, wrap it with Spool first")
+	}
+	c := &Request{
+		req:          r.req.Clone(ctx),
+		bodyValue:    r.bodyValue,
+		extraSteps:   slices.Clone(r.extraSteps),
+		skippedSteps: slices.Clone(r.skippedSteps),
+	}
+	if r.body == nil {
+		return c, nil
+	}
+
+	body, err := r.cloneBody()
+	if err != nil {
+		return nil, err
+	}
+	if err := c.SetBody(body, r.req.Header.Get(HeaderContentType)); err != nil {
+		return nil, err
+	}
+	// SetBody clears the value set with SetBodyAs
+	c.bodyValue = r.bodyValue
+	return c, nil
+}
+
+// cloneBody returns a reader over the request body with an independent read position.
+func (r *Request) cloneBody() (io.ReadSeekCloser, error) {
+	var rs io.ReadSeeker = r.body
+	if nc, ok := r.body.(nopCloser); ok {
+		rs = nc.ReadSeeker
+	}
+	if sb, ok := rs.(*sectionBody); ok {
+		rs = sb.SectionReader
+	}
+
+	if ra, ok := rs.(io.ReaderAt); ok {
+		size, err := bodySize(rs)
+		if err != nil {
+			return nil, err
+		}
+		return &sectionBody{io.NewSectionReader(ra, 0, size)}, nil
+	}
+
+	b, err := r.bodyBytes()
+	if err != nil {
+		return nil, err
+	}
+	return NopCloser(bytes.NewReader(b)), nil
+}
+
+// bodySize returns the size of rs, without moving its read position when
+// the reader can report its size.
+func bodySize(rs io.ReadSeeker) (int64, error) {
+	switch v := rs.(type) {
+	case interface{ Size() int64 }:
+		return v.Size(), nil
+	case interface{ Stat() (os.FileInfo, error) }:
+		if fi, err := v.Stat(); err == nil && fi.Mode().IsRegular() {
+			return fi.Size(), nil
+		}
+	}
+	cur, err := rs.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	size, err := rs.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+	_, err = rs.Seek(cur, io.SeekStart)
+	return size, err
+}
+
+// sectionBody is a cloned body. Closing it leaves the original body open.
+type sectionBody struct {
+	*io.SectionReader
+}
+
+func (sectionBody) Close() error {
+	return nil
+}
@@ -3,22 +3,22 @@ package choco
 import (
 	"context"
 	"fmt"
+	"io"
 	"net/http"
+	"strings"
+	"sync"
 	"testing"
+
+	"nyxze/choco-go/chocotest"
 )
 
 const testURL = "http://www.example.com/"
 
-type fakeExec struct {
-}
-
-func (fakeExec) Send(*http.Request) (*http.Response, error) {
-	header := http.Header{}
-	header.Add("Ping", "Pong")
-
-	return &http.Response{
-		Header: header,
-	}, nil
+// pingTransport answers every request with a "Ping: Pong" header.
+func pingTransport() *chocotest.Transport {
+	tr := chocotest.NewTransport()
+	tr.On("*", "").Respond(chocotest.Status(http.StatusOK).WithHeader("Ping", "Pong"))
+	return tr
 }
 
 func TestNewRequest(t *testing.T) {
@@ -69,7 +69,7 @@ func TestPipelineSteps(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			state.logs = nil
 			p, err := NewPipeline(
-				WithCustomTransport(fakeExec{}),
+				WithCustomTransport(pingTransport()),
 				WithSteps(tt.steps...),
 			)
 			if err != nil {
@@ -145,3 +145,82 @@ func (s *statefullStep) Do(req *Request, next RequestHandlerFunc) (*http.Respons
 	}
 	return resp, err
 }
+
+func TestRequestClone(t *testing.T) {
+	ctx := context.Background()
+	newBodyRequest := func(t *testing.T, body io.ReadSeeker) *Request {
+		t.Helper()
+		req, err := NewRequest(ctx, "POST", testURL+"?a=1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.SetHeader("X-Test", "original")
+		if err := req.SetBody(NopCloser(body), ContentTypeTextPlain); err != nil {
+			t.Fatal(err)
+		}
+		return req
+	}
+
+	tests := []struct {
+		name string
+		body io.ReadSeeker
+	}{
+		{"reader at", strings.NewReader("kweh kweh")},
+		{"plain seeker", struct{ io.ReadSeeker }{strings.NewReader("kweh kweh")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := newBodyRequest(t, tt.body)
+			type key struct{}
+			cctx := context.WithValue(ctx, key{}, "clone")
+
+			var wg sync.WaitGroup
+			bodies := make([]string, 8)
+			for i := range bodies {
+				clone, err := req.Clone(cctx)
+				if err != nil {
+					t.Fatal(err)
+				}
+				clone.SetHeader("X-Test", "clone")
+				clone.SetQuery("a", "2")
+				if clone.Raw().Context().Value(key{}) != "clone" {
+					t.Error("clone does not use the new context")
+				}
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					b, _ := io.ReadAll(clone.Raw().Body)
+					bodies[i] = string(b)
+				}()
+			}
+			wg.Wait()
+
+			for i, b := range bodies {
+				if b != "kweh kweh" {
+					t.Errorf("clone %d read %q", i, b)
+				}
+			}
+			if got := req.Raw().Header.Get("X-Test"); got != "original" {
+				t.Errorf("original header changed to %q", got)
+			}
+			if got := req.Raw().URL.RawQuery; got != "a=1" {
+				t.Errorf("original query changed to %q", got)
+			}
+			if b, _ := io.ReadAll(req.Body()); string(b) != "kweh kweh" {
+				t.Errorf("original body = %q", b)
+			}
+		})
+	}
+
+	t.Run("body value", func(t *testing.T) {
+		req := newBodyRequest(t, strings.NewReader("kweh kweh"))
+		req.SetBodyAs(map[string]string{"name": "clou"})
+		clone, err := req.Clone(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if clone.bodyValue == nil {
+			t.Error("clone lost the value set with SetBodyAs")
+		}
+	})
+}
@@ -0,0 +1,128 @@
+package choco
+
+import (
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+)
+
+// [RequestPredicate] reports whether a [PipelineStep] applies to a [Request].
+type RequestPredicate func(*Request) bool
+
+// MatchHost returns a [RequestPredicate] matching requests whose host matches
+// pattern, using the syntax of [path.Match] and ignoring case. The port is
+// only compared when pattern has one, so "*.example.com" matches
+// "api.example.com:8443".
+func MatchHost(pattern string) RequestPredicate {
+	pattern = strings.ToLower(pattern)
+	withPort := strings.Contains(pattern, ":")
+	return func(r *Request) bool {
+		u := r.Raw().URL
+		host := u.Hostname()
+		if withPort {
+			host = u.Host
+		}
+		ok, _ := path.Match(pattern, strings.ToLower(host))
+		return ok
+	}
+}
+
+// MatchMethod returns a [RequestPredicate] matching requests using one of methods.
+func MatchMethod(methods ...string) RequestPredicate {
+	return func(r *Request) bool {
+		return slices.ContainsFunc(methods, func(m string) bool {
+			return strings.EqualFold(m, r.Raw().Method)
+		})
+	}
+}
+
+// MatchPathPrefix returns a [RequestPredicate] matching requests whose path
+// starts with prefix. Whole segments are compared, so "/api" matches "/api"
+// and "/api/users" but not "/apikeys".
+func MatchPathPrefix(prefix string) RequestPredicate {
+	prefix = strings.TrimSuffix(prefix, "/")
+	return func(r *Request) bool {
+		p := r.Raw().URL.Path
+		if !strings.HasPrefix(p, prefix) {
+			return false
+		}
+		return len(p) == len(prefix) || p[len(prefix)] == '/'
+	}
+}
+
+// When returns a [PipelineStep] running step only for requests matching pred.
+// Other requests are passed straight to [next]. The returned step keeps the
+// name of step (see [NamedStep]), so it can still be skipped or located.
+//
+// Example:
+//
+//	pipeline, err := NewPipeline(WithSteps(
+//	    When(func(r *Request) bool { return r.Raw().Header.Get("Idempotency-Key") != "" }, retryStep),
+//	))
+func When(pred RequestPredicate, step PipelineStep) PipelineStep {
+	cond := PipelineStepFunc(func(req *Request, next RequestHandlerFunc) (*http.Response, error) {
+		if !pred(req) {
+			return next(req)
+		}
+		return step.Do(req, next)
+	})
+	if name := StepName(step); name != "" {
+		return Named(name, cond)
+	}
+	return cond
+}
+
+// ForHost runs step only for requests to hosts matching pattern (see [MatchHost]).
+func ForHost(pattern string, step PipelineStep) PipelineStep {
+	return When(MatchHost(pattern), step)
+}
+
+// ForMethods runs step only for requests using one of methods (see [MatchMethod]).
+func ForMethods(methods []string, step PipelineStep) PipelineStep {
+	return When(MatchMethod(methods...), step)
+}
+
+// ForPathPrefix runs step only for requests whose path starts with prefix (see [MatchPathPrefix]).
+func ForPathPrefix(prefix string, step PipelineStep) PipelineStep {
+	return When(MatchPathPrefix(prefix), step)
+}
+
+// [Route] is a rule of a [Router]: requests matching Match go through Steps.
+// A nil Match matches every request.
+type Route struct {
+	Match RequestPredicate
+	Steps []PipelineStep
+}
+
+// [Router] is a [PipelineStep] sending each request through the steps of the
+// first [Route] it matches, then on to [next]. Requests matching no route
+// are passed straight to [next].
+type Router struct {
+	routes []Route
+}
+
+// NewRouter creates a [Router] trying routes in order.
+//
+// Example:
+//
+//	router := NewRouter(
+//	    Route{Match: MatchHost("api.github.com"), Steps: []PipelineStep{githubAuth, retry}},
+//	    Route{Match: MatchHost("*.internal"), Steps: []PipelineStep{mtlsHeaders}},
+//	    Route{Steps: []PipelineStep{defaultAuth}},
+//	)
+//	pipeline, err := NewPipeline(WithSteps(logging, router))
+func NewRouter(routes ...Route) *Router {
+	return &Router{routes: slices.Clone(routes)}
+}
+
+// Do implements [PipelineStep].
+func (rt *Router) Do(req *Request, next RequestHandlerFunc) (*http.Response, error) {
+	for _, route := range rt.routes {
+		if route.Match != nil && !route.Match(req) {
+			continue
+		}
+		return buildChain(req, route.Steps, next)(req)
+	}
+	return next(req)
+}
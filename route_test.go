@@ -0,0 +1,110 @@
+package choco
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// tagStep appends its name to the X-Steps header of the request.
+func tagStep(name string) PipelineStep {
+	return PipelineStepFunc(func(req *Request, next RequestHandlerFunc) (*http.Response, error) {
+		req.Raw().Header.Add("X-Steps", name)
+		return next(req)
+	})
+}
+
+func routedSteps(t *testing.T, steps []PipelineStep, method, url string) string {
+	t.Helper()
+	tr := pingTransport()
+	p, err := NewPipeline(WithCustomTransport(tr), WithSteps(steps...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := NewRequest(context.Background(), method, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Execute(req); err != nil {
+		t.Fatal(err)
+	}
+	return strings.Join(lastRequest(t, tr).Header.Values("X-Steps"), ",")
+}
+
+func TestConditionalSteps(t *testing.T) {
+	steps := []PipelineStep{
+		ForHost("*.example.com", tagStep("host")),
+		ForMethods([]string{"post", http.MethodPut}, tagStep("method")),
+		ForPathPrefix("/api/", tagStep("path")),
+		When(func(r *Request) bool { return r.Raw().URL.Query().Has("debug") }, tagStep("when")),
+	}
+	tests := []struct {
+		method, url, want string
+	}{
+		{"GET", "http://api.example.com:8443/", "host"},
+		{"GET", "http://EXAMPLE.com/", ""},
+		{"POST", "http://other.com/api", "method,path"},
+		{"PUT", "http://other.com/", "method"},
+		{"DELETE", "http://other.com/", ""},
+		{"GET", "http://other.com/apikeys", ""},
+		{"GET", "http://www.example.com/api/users?debug", "host,path,when"},
+	}
+	for _, tt := range tests {
+		if got := routedSteps(t, steps, tt.method, tt.url); got != tt.want {
+			t.Errorf("%s %s ran %q, want %q", tt.method, tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestConditionalStepsKeepName(t *testing.T) {
+	auth := ForHost("*.example.com", Named("auth", tagStep("auth")))
+	if name := StepName(auth); name != "auth" {
+		t.Fatalf("StepName() = %q, want auth", name)
+	}
+	tr := pingTransport()
+	p, err := NewPipeline(WithCustomTransport(tr), WithSteps(auth, tagStep("other")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, skip := range []bool{false, true} {
+		req, err := NewRequest(context.Background(), "GET", "http://api.example.com/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := "auth,other"
+		if skip {
+			req.SkipSteps("auth")
+			want = "other"
+		}
+		if _, err := p.Execute(req); err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Join(lastRequest(t, tr).Header.Values("X-Steps"), ","); got != want {
+			t.Errorf("skip=%v ran %q, want %q", skip, got, want)
+		}
+	}
+}
+
+func TestRouter(t *testing.T) {
+	router := NewRouter(
+		Route{Match: MatchHost("api.github.com"), Steps: []PipelineStep{tagStep("github"), tagStep("retry")}},
+		Route{Match: MatchHost("*.internal:8080"), Steps: []PipelineStep{tagStep("internal")}},
+		Route{Match: MatchMethod("DELETE")},
+		Route{Steps: []PipelineStep{tagStep("default")}},
+	)
+	steps := []PipelineStep{tagStep("before"), router, tagStep("after")}
+	tests := []struct {
+		method, url, want string
+	}{
+		{"GET", "https://api.github.com/repos", "before,github,retry,after"},
+		{"GET", "http://db.internal:8080/", "before,internal,after"},
+		{"GET", "http://db.internal:9090/", "before,default,after"},
+		{"DELETE", "http://db.internal:9090/", "before,after"},
+	}
+	for _, tt := range tests {
+		if got := routedSteps(t, steps, tt.method, tt.url); got != tt.want {
+			t.Errorf("%s %s ran %q, want %q", tt.method, tt.url, got, tt.want)
+		}
+	}
+}
@@ -3,10 +3,14 @@ package sse_test
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"io"
+	"net/http"
 	"strings"
 	"testing"
 
+	"nyxze/choco-go"
+	"nyxze/choco-go/chocotest"
 	"nyxze/choco-go/seqio"
 	"nyxze/choco-go/sse"
 )
@@ -116,3 +120,73 @@ func TestSseIter(t *testing.T) {
 		})
 	}
 }
+
+func TestSseIterFromPipeline(t *testing.T) {
+	tr := chocotest.NewTransport()
+	tr.On("GET", "/events").Respond(chocotest.SSE(
+		chocotest.Event{Event: "start", Data: "Hello"},
+		chocotest.Event{Event: "update", Data: "world!"},
+		chocotest.Event{Data: "[DONE]"},
+	))
+	p, err := choco.NewPipeline(choco.WithCustomTransport(tr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	req, err := choco.NewRequest(ctx, "GET", "http://www.example.com/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := p.Execute(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var results []TestEvent
+	for v := range seqio.Range(ctx, sse.NewSSEIter[TestEvent](resp.Body, "[DONE]")) {
+		results = append(results, v)
+	}
+	want := []TestEvent{{Event: "start", Data: "Hello"}, {Event: "update", Data: "world!"}}
+	if len(results) != len(want) {
+		t.Fatalf("expected %d events, got %d", len(want), len(results))
+	}
+	for i := range want {
+		if results[i].Event != want[i].Event || results[i].Data != want[i].Data {
+			t.Errorf("event %d mismatch\nGot:  %+v\nWant: %+v", i, results[i], want[i])
+		}
+	}
+	tr.AssertExpectations(t)
+}
+
+func TestSseIterFromHandler(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(choco.HeaderContentType, "text/event-stream")
+		for _, ev := range []string{"event: start\ndata: Hello", "event: update\ndata: world!", "data: [DONE]"} {
+			fmt.Fprintf(w, "%s\n\n", ev)
+			w.(http.Flusher).Flush()
+		}
+	})
+	p, err := choco.NewPipeline(choco.WithCustomTransport(choco.HandlerTransport(h)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	req, err := choco.NewRequest(ctx, "GET", "http://www.example.com/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := p.Execute(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var results []TestEvent
+	for v := range seqio.Range(ctx, sse.NewSSEIter[TestEvent](resp.Body, "[DONE]")) {
+		results = append(results, v)
+	}
+	if len(results) != 2 || results[0].Data != "Hello" || results[1].Event != "update" {
+		t.Errorf("events = %+v", results)
+	}
+}
@@ -0,0 +1,188 @@
+package choco
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"text/tabwriter"
+)
+
+// [NamedStep] is a [PipelineStep] with a stable name, used to skip or locate
+// it. Names should be unique within a [Pipeline].
+type NamedStep interface {
+	PipelineStep
+	Name() string
+}
+
+// Named gives a name to step.
+//
+// Example:
+//
+//	pipeline, err := NewPipeline(WithSteps(
+//	    Named("retry", retryStep),
+//	    Named("auth", authStep),
+//	))
+func Named(name string, step PipelineStep) NamedStep {
+	return namedStep{name: name, step: step}
+}
+
+type namedStep struct {
+	name string
+	step PipelineStep
+}
+
+func (s namedStep) Name() string { return s.name }
+
+func (s namedStep) Do(req *Request, next RequestHandlerFunc) (*http.Response, error) {
+	return s.step.Do(req, next)
+}
+
+// StepName returns the name of step, or "" when it does not implement [NamedStep].
+func StepName(step PipelineStep) string {
+	if ns, ok := step.(NamedStep); ok {
+		return ns.Name()
+	}
+	return ""
+}
+
+// AddSteps adds steps to run for this request only. They run after the steps
+// of the [Pipeline], just before the request is sent.
+func (r *Request) AddSteps(steps ...PipelineStep) {
+	r.extraSteps = slices.Concat(r.extraSteps, steps)
+}
+
+// SkipSteps disables the named steps (see [NamedStep]) for this request only.
+// Both pipeline steps and steps added with [Request.AddSteps] are skipped.
+//
+// Example:
+//
+//	req.SkipSteps("retry")
+//	resp, err := pipeline.Execute(req)
+func (r *Request) SkipSteps(names ...string) {
+	r.skippedSteps = slices.Concat(r.skippedSteps, names)
+}
+
+// skips reports whether step is disabled for this request.
+func (r *Request) skips(step PipelineStep) bool {
+	if len(r.skippedSteps) == 0 {
+		return false
+	}
+	name := StepName(step)
+	return name != "" && slices.Contains(r.skippedSteps, name)
+}
+
+// buildChain wraps next with the steps that apply to req, the first step
+// being the outermost.
+func buildChain(req *Request, steps []PipelineStep, next RequestHandlerFunc) RequestHandlerFunc {
+	for i := len(steps) - 1; i >= 0; i-- {
+		if req.skips(steps[i]) {
+			continue
+		}
+		next = wrapStep(steps[i], next)
+	}
+	return next
+}
+
+// [Phase] is a fixed position in the chain of a [Pipeline]. Steps of an
+// earlier phase wrap the steps of later phases, whatever order the options
+// adding them were given in. Within a phase, steps run in the order added.
+type Phase int
+
+const (
+	// PerCall steps run once per call to [Pipeline.Execute]: logging,
+	// tracing, retries. This is the phase of [WithSteps].
+	PerCall Phase = iota
+	// PerRetry steps run on every attempt of a retrying PerCall step:
+	// authentication, signing, per-attempt timeouts.
+	PerRetry
+	// BeforeTransport steps run last, right before the request is sent.
+	BeforeTransport
+
+	numPhases
+)
+
+func (ph Phase) String() string {
+	switch ph {
+	case PerCall:
+		return "per-call"
+	case PerRetry:
+		return "per-retry"
+	case BeforeTransport:
+		return "before-transport"
+	}
+	return fmt.Sprintf("Phase(%d)", int(ph))
+}
+
+// stepInsert is a step placement by name, resolved once all options are applied.
+type stepInsert struct {
+	target string
+	after  bool
+	steps  []PipelineStep
+}
+
+// buildSteps resolves the insertions and flattens the phases into the
+// chain run by [Pipeline.Execute].
+func (p *Pipeline) buildSteps() error {
+	phases := p.phases
+	for ph := range phases {
+		phases[ph] = slices.Clone(phases[ph])
+	}
+	for _, ins := range p.inserts {
+		found := false
+		for ph := range phases {
+			i := slices.IndexFunc(phases[ph], func(s PipelineStep) bool {
+				return StepName(s) == ins.target
+			})
+			if i < 0 {
+				continue
+			}
+			if ins.after {
+				i++
+			}
+			phases[ph] = slices.Insert(phases[ph], i, ins.steps...)
+			found = true
+			break
+		}
+		if !found {
+			return NewError("pipeline: no step named %q to insert next to", ins.target)
+		}
+	}
+	p.steps = slices.Concat(phases[:]...)
+	p.stepPhases = make([]Phase, 0, len(p.steps))
+	for ph := range phases {
+		for range phases[ph] {
+			p.stepPhases = append(p.stepPhases, Phase(ph))
+		}
+	}
+	return nil
+}
+
+// Describe lists the chain of the pipeline in execution order, one step per
+// line with its phase, name and type, followed by the transport. Per-request
+// steps (see [Request.AddSteps]) are not included.
+//
+// Example output:
+//
+//	per-call          logging    *main.logStep
+//	per-call          retry      *retry.Step
+//	per-retry         auth       choco.PipelineStepFunc
+//	before-transport  -          *limit.Step
+//	transport                    choco.defaultTransport
+func (p Pipeline) Describe() string {
+	var sb strings.Builder
+	tw := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	for i, step := range p.steps {
+		name := StepName(step)
+		if name == "" {
+			name = "-"
+		}
+		if ns, ok := step.(namedStep); ok {
+			step = ns.step
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%T\n", p.stepPhases[i], name, step)
+	}
+	fmt.Fprintf(tw, "transport\t\t%T\n", p.transport)
+	tw.Flush()
+	return sb.String()
+}
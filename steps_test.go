@@ -0,0 +1,128 @@
+package choco
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestPerRequestSteps(t *testing.T) {
+	pipelineSteps := []PipelineStep{
+		Named("retry", tagStep("retry")),
+		tagStep("anonymous"),
+		Named("auth", tagStep("auth")),
+	}
+	tests := []struct {
+		name  string
+		setup func(*Request)
+		want  string
+	}{
+		{"pipeline steps", func(*Request) {}, "retry,anonymous,auth"},
+		{"skip", func(r *Request) { r.SkipSteps("retry", "unknown") }, "anonymous,auth"},
+		{"extra steps run last", func(r *Request) {
+			r.AddSteps(Named("sign", tagStep("sign")), tagStep("extra"))
+		}, "retry,anonymous,auth,sign,extra"},
+		{"skip extra step", func(r *Request) {
+			r.AddSteps(Named("sign", tagStep("sign")))
+			r.SkipSteps("sign", "auth")
+		}, "retry,anonymous"},
+		{"skip inside router", func(r *Request) {
+			r.AddSteps(NewRouter(Route{Steps: []PipelineStep{Named("inner", tagStep("inner")), tagStep("kept")}}))
+			r.SkipSteps("inner")
+		}, "retry,anonymous,auth,kept"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := pingTransport()
+			p, err := NewPipeline(WithCustomTransport(tr), WithSteps(pipelineSteps...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req, err := NewRequest(context.Background(), "GET", testURL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.setup(req)
+			if _, err := p.Execute(req); err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Join(lastRequest(t, tr).Header.Values("X-Steps"), ","); got != tt.want {
+				t.Errorf("steps = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCloneKeepsStepOverrides(t *testing.T) {
+	req, err := NewRequest(context.Background(), "GET", testURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.AddSteps(tagStep("extra"))
+	req.SkipSteps("retry")
+
+	c, err := req.Clone(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SkipSteps("auth")
+	if len(c.extraSteps) != 1 || len(c.skippedSteps) != 2 || len(req.skippedSteps) != 1 {
+		t.Errorf("clone overrides = %d extra, %d skipped; original skipped = %d",
+			len(c.extraSteps), len(c.skippedSteps), len(req.skippedSteps))
+	}
+	if StepName(Named("x", tagStep("x"))) != "x" || StepName(tagStep("x")) != "" {
+		t.Error("unexpected StepName result")
+	}
+}
+
+func TestPhasesAndInserts(t *testing.T) {
+	tr := pingTransport()
+	p, err := NewPipeline(
+		WithCustomTransport(tr),
+		InsertAfter("logging", Named("tracing", tagStep("tracing"))),
+		WithPhaseSteps(BeforeTransport, Named("sign", tagStep("sign"))),
+		InsertBefore("auth", tagStep("refresh")),
+		WithPhaseSteps(PerRetry, Named("auth", tagStep("auth"))),
+		WithSteps(Named("logging", tagStep("logging")), Named("retry", tagStep("retry"))),
+		InsertAfter("tracing", tagStep("metrics")),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := NewRequest(context.Background(), "GET", testURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Execute(req); err != nil {
+		t.Fatal(err)
+	}
+	want := "logging,tracing,metrics,retry,refresh,auth,sign"
+	if got := strings.Join(lastRequest(t, tr).Header.Values("X-Steps"), ","); got != want {
+		t.Errorf("steps = %q, want %q", got, want)
+	}
+
+	desc := p.Describe()
+	lines := strings.Split(strings.TrimSpace(desc), "\n")
+	if len(lines) != 8 {
+		t.Fatalf("Describe() =\n%s", desc)
+	}
+	for i, want := range []string{"per-call logging", "per-call tracing", "per-call -", "per-call retry",
+		"per-retry -", "per-retry auth", "before-transport sign", "transport *chocotest.Transport"} {
+		if got := strings.Join(strings.Fields(lines[i])[:2], " "); i < 7 && got != want {
+			t.Errorf("Describe() line %d = %q, want %q", i, got, want)
+		}
+		if i == 7 && strings.Join(strings.Fields(lines[i]), " ") != want {
+			t.Errorf("Describe() line %d = %q, want %q", i, lines[i], want)
+		}
+	}
+	if !strings.Contains(lines[0], "choco.PipelineStepFunc") {
+		t.Errorf("Describe() does not show the type of named steps: %q", lines[0])
+	}
+
+	if _, err := NewPipeline(InsertBefore("missing", tagStep("x"))); err == nil {
+		t.Error("expected error inserting next to an unknown step")
+	}
+	if _, err := NewPipeline(WithPhaseSteps(Phase(7), tagStep("x"))); err == nil {
+		t.Error("expected error for an unknown phase")
+	}
+}
@@ -0,0 +1,116 @@
+package choco
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// SetStreamBody sets a non-seekable reader, such as a pipe or a generator, as
+// the HTTP request body.
+//   - body is read once, when the request is sent; if it is an [io.ReadCloser] it is closed afterwards
+//   - size is the body length in bytes; a negative size means unknown and the body is sent with chunked transfer encoding
+//   - contentType is the value for the Content-Type header; if empty, Content-Type will be deleted
+//
+// A streaming body cannot be rewound, so the request cannot be retried or cloned.
+// Wrap the reader with [Spool] and use [Request.SetBody] when that is needed.
+func (r *Request) SetStreamBody(body io.Reader, size int64, contentType string) error {
+	if body == nil {
+		return NewError("body is nil")
+	}
+	rc, ok := body.(io.ReadCloser)
+	if !ok {
+		rc = io.NopCloser(body)
+	}
+	if size < 0 {
+		size = -1
+	}
+
+	raw := r.Raw()
+	raw.Body = rc
+	raw.GetBody = nil
+	raw.ContentLength = size
+	r.DelHeader(HeaderContentLength)
+	if contentType == "" {
+		r.DelHeader(HeaderContentType)
+	} else {
+		r.SetContentType(contentType)
+	}
+	r.body = nil
+	r.stream = rc
+	r.bodyValue = nil
+	return nil
+}
+
+// DefaultSpoolMemoryLimit is the memory threshold used by [Spool] when memLimit is zero.
+const DefaultSpoolMemoryLimit = 4 << 20
+
+// Spool reads src to the end and returns a rewindable copy of it, so that a
+// streaming source can be used with [Request.SetBody] and retried.
+//
+// Up to memLimit bytes are kept in memory; larger contents are spilled to a
+// temporary file, which is removed when the returned body is closed.
+// A zero memLimit uses [DefaultSpoolMemoryLimit]. If src is an [io.Closer]
+// it is closed once consumed.
+func Spool(src io.Reader, memLimit int64) (io.ReadSeekCloser, error) {
+	if c, ok := src.(io.Closer); ok {
+		defer c.Close()
+	}
+	if memLimit <= 0 {
+		memLimit = DefaultSpoolMemoryLimit
+	}
+
+	var buf bytes.Buffer
+	n, err := io.CopyN(&buf, src, memLimit+1)
+	if err == io.EOF {
+		return &spooledBody{spoolReader: bytes.NewReader(buf.Bytes()), size: n}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.CreateTemp("", "choco-spool-*")
+	if err != nil {
+		return nil, err
+	}
+	size, err := io.Copy(f, io.MultiReader(&buf, src))
+	if err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return &spooledBody{spoolReader: f, size: size, file: f}, nil
+}
+
+type spoolReader interface {
+	io.ReadSeeker
+	io.ReaderAt
+}
+
+// spooledBody is a rewindable body held in memory or in a temporary file.
+type spooledBody struct {
+	spoolReader
+	size int64
+	file *os.File
+}
+
+// Size returns the spooled content length.
+func (s *spooledBody) Size() int64 {
+	return s.size
+}
+
+// Close removes the temporary file, if any.
+func (s *spooledBody) Close() error {
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	if rmErr := os.Remove(s.file.Name()); err == nil {
+		err = rmErr
+	}
+	s.file = nil
+	return err
+}
@@ -0,0 +1,92 @@
+package choco
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSetStreamBody(t *testing.T) {
+	req, err := NewRequest(context.Background(), "POST", testURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		pw.Write([]byte("streamed"))
+		pw.Close()
+	}()
+	if err := req.SetStreamBody(pr, -1, ContentTypeTextPlain); err != nil {
+		t.Fatal(err)
+	}
+	raw := req.Raw()
+	if raw.ContentLength != -1 || raw.GetBody != nil {
+		t.Errorf("ContentLength = %d, GetBody set = %v; want unknown size and no rewind", raw.ContentLength, raw.GetBody != nil)
+	}
+	if b, _ := io.ReadAll(raw.Body); string(b) != "streamed" {
+		t.Errorf("body = %q", b)
+	}
+	if _, err := req.Clone(context.Background()); err == nil {
+		t.Error("expected error cloning a streaming body")
+	}
+}
+
+func TestSpool(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		memLimit int64
+		onDisk   bool
+	}{
+		{"memory", "small body", 64, false},
+		{"temp file", strings.Repeat("x", 100), 10, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := Spool(io.NopCloser(strings.NewReader(tt.content)), tt.memLimit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sb := body.(*spooledBody)
+			if (sb.file != nil) != tt.onDisk {
+				t.Errorf("spooled on disk = %v, want %v", sb.file != nil, tt.onDisk)
+			}
+			var name string
+			if sb.file != nil {
+				name = sb.file.Name()
+			}
+
+			req, err := NewRequest(context.Background(), "PUT", testURL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := req.SetBody(body, ContentTypeTextPlain); err != nil {
+				t.Fatal(err)
+			}
+			if req.Raw().ContentLength != int64(len(tt.content)) {
+				t.Errorf("ContentLength = %d", req.Raw().ContentLength)
+			}
+			// Read twice, as a retry would.
+			for range 2 {
+				rc, err := req.Raw().GetBody()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if b, _ := io.ReadAll(rc); string(b) != tt.content {
+					t.Errorf("body = %q", b)
+				}
+			}
+
+			if err := req.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if name != "" {
+				if _, err := os.Stat(name); !os.IsNotExist(err) {
+					t.Errorf("temp file %s not removed", name)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package choco
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// [SchemeTransport] is implemented by [Transport]s able to send requests with
+// URL schemes other than http and https. [Pipeline.Execute] refuses any other
+// scheme unless the transport supports it.
+type SchemeTransport interface {
+	Transport
+	SupportsScheme(scheme string) bool
+}
+
+// [UnixTransport] is a [Transport] sending requests over unix domain sockets,
+// for local daemons such as container runtimes or sidecars. It is safe for
+// concurrent use, and keeps one connection pool per socket.
+//
+// A socket is addressed either:
+//   - in the URL, with the unix scheme: the socket path and the request path
+//     are separated by a colon, as in unix:///var/run/docker.sock:/v1.43/info;
+//   - by hostname, with [UnixTransport.Map]: http://docker/v1.43/info is sent
+//     to the socket mapped to "docker".
+//
+// Other requests are sent over TCP as usual, but never through a proxy.
+type UnixTransport struct {
+	client *http.Client
+
+	mu      sync.RWMutex
+	sockets map[string]string // Dial address host -> socket path
+}
+
+// NewUnixTransport creates a [UnixTransport] with the given hostname to socket
+// path mapping, which may be nil.
+//
+// Example:
+//
+//	tr := NewUnixTransport(map[string]string{"docker": "/var/run/docker.sock"})
+//	pipeline, err := NewPipeline(WithCustomTransport(tr))
+//	req, err := NewRequest(ctx, "GET", "http://docker/v1.43/info")
+func NewUnixTransport(sockets map[string]string) *UnixTransport {
+	t := &UnixTransport{sockets: map[string]string{}}
+	for host, path := range sockets {
+		t.Map(host, path)
+	}
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// Proxies from the environment would receive the requests meant for sockets.
+	transport.Proxy = nil
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if path, ok := t.socket(addr); ok {
+			return dialer.DialContext(ctx, "unix", path)
+		}
+		return dialer.DialContext(ctx, network, addr)
+	}
+	t.client = &http.Client{Transport: transport}
+	return t
+}
+
+// Map sends the requests to host over the socket at path.
+func (t *UnixTransport) Map(host, path string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.sockets[strings.ToLower(host)] = path
+}
+
+// SupportsScheme implements [SchemeTransport] for the unix scheme.
+func (t *UnixTransport) SupportsScheme(scheme string) bool {
+	return scheme == "unix"
+}
+
+// Send implements [Transport].
+func (t *UnixTransport) Send(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "unix" {
+		return t.client.Do(req)
+	}
+
+	socket, reqPath, ok := strings.Cut(req.URL.Path, ":")
+	if !ok || socket == "" {
+		return nil, NewError("unix: URL %q must be unix://<socket path>:<request path>", req.URL)
+	}
+	if reqPath == "" {
+		reqPath = "/"
+	}
+	// Each socket gets its own host name, and so its own connection pool.
+	h := fnv.New64a()
+	h.Write([]byte(socket))
+	host := fmt.Sprintf("unix-%x.sock", h.Sum64())
+	t.mu.Lock()
+	t.sockets[host] = socket
+	t.mu.Unlock()
+
+	out := req.Clone(req.Context())
+	out.URL.Scheme = "http"
+	out.URL.Host = host
+	out.URL.Path = reqPath
+	out.URL.RawPath = ""
+	if out.Host == "" {
+		out.Host = "localhost"
+	}
+	return t.client.Do(out)
+}
+
+// socket returns the socket path for a dial address.
+func (t *UnixTransport) socket(addr string) (string, bool) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	path, ok := t.sockets[strings.ToLower(host)]
+	return path, ok
+}
@@ -0,0 +1,111 @@
+package choco
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// serveUnix serves the request path and Host header over a new unix socket.
+func serveUnix(t *testing.T, name string) string {
+	t.Helper()
+	// Socket paths are limited to about 100 bytes, shorter than most t.TempDir paths.
+	dir, err := os.MkdirTemp("", "choco")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, name+".sock")
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s %s %s?%s", name, r.Host, r.URL.Path, r.URL.RawQuery)
+	})}
+	go srv.Serve(l)
+	t.Cleanup(func() { srv.Close() })
+	return path
+}
+
+func TestUnixTransport(t *testing.T) {
+	docker := serveUnix(t, "docker")
+	sidecar := serveUnix(t, "sidecar")
+	tr := NewUnixTransport(map[string]string{"Sidecar": sidecar})
+	p, err := NewPipeline(WithCustomTransport(tr))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		url, want string
+	}{
+		{"unix://" + docker + ":/v1.43/info?all=1", "docker localhost /v1.43/info?all=1"},
+		{"unix://" + docker + ":", "docker localhost /?"},
+		{"unix://" + sidecar + ":/health", "sidecar localhost /health?"},
+		{"http://sidecar/metrics?x=y", "sidecar sidecar /metrics?x=y"},
+	}
+	for _, tt := range tests {
+		req, err := NewRequest(context.Background(), "GET", tt.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := p.Execute(req)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.url, err)
+		}
+		b, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(b) != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.url, b, tt.want)
+		}
+	}
+
+	req, err := NewRequest(context.Background(), "GET", "unix:///no/request/path")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Execute(req); err == nil {
+		t.Error("expected error for a unix URL without request path")
+	}
+
+	// Without a transport supporting it, the unix scheme is still refused.
+	def, err := NewPipeline()
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err = NewRequest(context.Background(), "GET", "unix://"+docker+":/info")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := def.Execute(req); err == nil {
+		t.Error("expected unsupported scheme error")
+	}
+}
+
+func TestUnixTransportIgnoresProxy(t *testing.T) {
+	t.Setenv("HTTP_PROXY", "http://127.0.0.1:1")
+	t.Setenv("HTTPS_PROXY", "http://127.0.0.1:1")
+	sidecar := serveUnix(t, "sidecar")
+	p, err := NewPipeline(WithCustomTransport(NewUnixTransport(map[string]string{"sidecar": sidecar})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, url := range []string{"http://sidecar/health", "unix://" + sidecar + ":/health"} {
+		req, err := NewRequest(context.Background(), "GET", url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := p.Execute(req)
+		if err != nil {
+			t.Fatalf("%s: %v", url, err)
+		}
+		resp.Body.Close()
+	}
+}
@@ -0,0 +1,379 @@
+package choco
+
+import (
+	"context"
+	"encoding"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// NewRequestTemplate creates a new request whose endpoint is the expansion of
+// the RFC 6570 URI template tmpl (levels 1 to 4) with the given variables.
+//
+// Example:
+//
+//	req, err := NewRequestTemplate(ctx, "GET", "/users/{id}/repos{?page,per_page}", map[string]any{
+//	    "id":   "octo cat",
+//	    "page": 2,
+//	})
+//	// GET /users/octo%20cat/repos?page=2
+//
+// See [ExpandURITemplate] for the supported value types.
+func NewRequestTemplate(ctx context.Context, httpMethod string, tmpl string, vars map[string]any) (*Request, error) {
+	endpoint, err := ExpandURITemplate(tmpl, vars)
+	if err != nil {
+		return nil, err
+	}
+	return NewRequest(ctx, httpMethod, endpoint)
+}
+
+// ExpandURITemplate expands the RFC 6570 URI template tmpl using vars.
+//
+// Values may be strings, booleans, numbers, [encoding.TextMarshaler] or
+// [fmt.Stringer] implementations (scalars), slices or arrays of those (lists),
+// and maps with string keys (associative arrays, expanded in key order).
+// A nil value, an empty list or an empty map is undefined and expands to nothing.
+//
+// A variable that is absent from vars is an error naming the variable, except in
+// form-style query expansions ({?...} and {&...}) where it is simply omitted.
+func ExpandURITemplate(tmpl string, vars map[string]any) (string, error) {
+	var sb strings.Builder
+	for len(tmpl) > 0 {
+		open := strings.IndexByte(tmpl, '{')
+		if open < 0 {
+			if strings.IndexByte(tmpl, '}') >= 0 {
+				return "", NewError("uritemplate: unmatched '}' in template")
+			}
+			sb.WriteString(encodeTemplateLiteral(tmpl))
+			break
+		}
+		if strings.IndexByte(tmpl[:open], '}') >= 0 {
+			return "", NewError("uritemplate: unmatched '}' in template")
+		}
+		sb.WriteString(encodeTemplateLiteral(tmpl[:open]))
+
+		end := strings.IndexByte(tmpl[open:], '}')
+		if end < 0 {
+			return "", NewError("uritemplate: unterminated expression %q", tmpl[open:])
+		}
+		if err := expandExpression(&sb, tmpl[open+1:open+end], vars); err != nil {
+			return "", err
+		}
+		tmpl = tmpl[open+end+1:]
+	}
+	return sb.String(), nil
+}
+
+// templateOp holds the expansion rules of an RFC 6570 operator (Appendix A).
+type templateOp struct {
+	first         string
+	sep           string
+	named         bool
+	ifEmpty       string
+	allowReserved bool
+	optional      bool
+}
+
+var templateOps = map[byte]templateOp{
+	'+': {sep: ",", allowReserved: true},
+	'#': {first: "#", sep: ",", allowReserved: true},
+	'.': {first: ".", sep: "."},
+	'/': {first: "/", sep: "/"},
+	';': {first: ";", sep: ";", named: true},
+	'?': {first: "?", sep: "&", named: true, ifEmpty: "=", optional: true},
+	'&': {first: "&", sep: "&", named: true, ifEmpty: "=", optional: true},
+}
+
+func expandExpression(sb *strings.Builder, expr string, vars map[string]any) error {
+	if expr == "" {
+		return NewError("uritemplate: empty expression")
+	}
+	op := templateOp{sep: ","}
+	if o, ok := templateOps[expr[0]]; ok {
+		op = o
+		expr = expr[1:]
+	} else if strings.ContainsRune("=,!@|", rune(expr[0])) {
+		return NewError("uritemplate: reserved operator %q", expr[0])
+	}
+
+	first := true
+	for spec := range strings.SplitSeq(expr, ",") {
+		name, explode, prefix, err := parseVarSpec(spec)
+		if err != nil {
+			return err
+		}
+		value, ok := vars[name]
+		if !ok {
+			if op.optional {
+				continue
+			}
+			return NewError("uritemplate: missing variable %q", name)
+		}
+		v, defined := templateValue(value)
+		if !defined {
+			continue
+		}
+
+		if first {
+			sb.WriteString(op.first)
+			first = false
+		} else {
+			sb.WriteString(op.sep)
+		}
+		if err := expandValue(sb, op, name, v, explode, prefix); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func parseVarSpec(spec string) (name string, explode bool, prefix int, err error) {
+	name = spec
+	if n, ok := strings.CutSuffix(spec, "*"); ok {
+		name, explode = n, true
+	} else if n, p, ok := strings.Cut(spec, ":"); ok {
+		name = n
+		if _, err := fmt.Sscanf(p, "%d", &prefix); err != nil || prefix <= 0 || prefix >= 10000 {
+			return "", false, 0, NewError("uritemplate: invalid prefix in %q", spec)
+		}
+	}
+	if name == "" {
+		return "", false, 0, NewError("uritemplate: empty variable name")
+	}
+	for _, c := range []byte(name) {
+		if !(isAlphaNum(c) || c == '_' || c == '.' || c == '%') {
+			return "", false, 0, NewError("uritemplate: invalid variable name %q", name)
+		}
+	}
+	return name, explode, prefix, nil
+}
+
+func expandValue(sb *strings.Builder, op templateOp, name string, v reflect.Value, explode bool, prefix int) error {
+	kind := v.Kind()
+	if isTemplateScalar(v) {
+		kind = reflect.String
+	}
+	switch kind {
+	case reflect.Slice, reflect.Array:
+		items, err := templateList(v)
+		if err != nil {
+			return NewError("uritemplate: variable %q: %v", name, err)
+		}
+		if prefix > 0 {
+			return NewError("uritemplate: variable %q: prefix not allowed on a list", name)
+		}
+		if !explode {
+			if op.named {
+				sb.WriteString(name + "=")
+			}
+			for i, item := range items {
+				if i > 0 {
+					sb.WriteByte(',')
+				}
+				sb.WriteString(encodeTemplateValue(item, op.allowReserved))
+			}
+			return nil
+		}
+		for i, item := range items {
+			if i > 0 {
+				sb.WriteString(op.sep)
+			}
+			writeNamed(sb, op, name, item, op.named)
+		}
+		return nil
+
+	case reflect.Map:
+		pairs, err := templateMap(v)
+		if err != nil {
+			return NewError("uritemplate: variable %q: %v", name, err)
+		}
+		if prefix > 0 {
+			return NewError("uritemplate: variable %q: prefix not allowed on a map", name)
+		}
+		if !explode {
+			if op.named {
+				sb.WriteString(name + "=")
+			}
+			for i, kv := range pairs {
+				if i > 0 {
+					sb.WriteByte(',')
+				}
+				sb.WriteString(encodeTemplateValue(kv[0], op.allowReserved))
+				sb.WriteByte(',')
+				sb.WriteString(encodeTemplateValue(kv[1], op.allowReserved))
+			}
+			return nil
+		}
+		for i, kv := range pairs {
+			if i > 0 {
+				sb.WriteString(op.sep)
+			}
+			writeNamed(sb, op, kv[0], kv[1], true)
+		}
+		return nil
+
+	default:
+		s, err := templateScalar(v)
+		if err != nil {
+			return NewError("uritemplate: variable %q: %v", name, err)
+		}
+		if prefix > 0 && utf8.RuneCountInString(s) > prefix {
+			s = string([]rune(s)[:prefix])
+		}
+		writeNamed(sb, op, name, s, op.named)
+		return nil
+	}
+}
+
+// writeNamed writes value, preceded by "name=" (or the operator's empty form) when named.
+func writeNamed(sb *strings.Builder, op templateOp, name, value string, named bool) {
+	if named {
+		sb.WriteString(encodeTemplateValue(name, op.allowReserved))
+		if value == "" {
+			sb.WriteString(op.ifEmpty)
+			return
+		}
+		sb.WriteByte('=')
+	}
+	sb.WriteString(encodeTemplateValue(value, op.allowReserved))
+}
+
+// templateValue unwraps v and reports whether it is defined in the RFC 6570 sense.
+func templateValue(value any) (reflect.Value, bool) {
+	if value == nil {
+		return reflect.Value{}, false
+	}
+	v := reflect.ValueOf(value)
+	for !isTemplateScalar(v) && (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return v, false
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		if isTemplateScalar(v) {
+			return v, true
+		}
+		return v, v.Len() > 0
+	}
+	return v, true
+}
+
+// isTemplateScalar reports whether v expands as a single string value.
+func isTemplateScalar(v reflect.Value) bool {
+	if !v.IsValid() {
+		return false
+	}
+	if (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && v.IsNil() {
+		return false
+	}
+	t := v.Type()
+	if t.Implements(textMarshalerType) || t.Implements(stringerType) {
+		return true
+	}
+	return t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8
+}
+
+func templateScalar(v reflect.Value) (string, error) {
+	switch x := v.Interface().(type) {
+	case encoding.TextMarshaler:
+		b, err := x.MarshalText()
+		return string(b), err
+	case fmt.Stringer:
+		return x.String(), nil
+	}
+	return formatQueryValue(v, false)
+}
+
+func templateList(v reflect.Value) ([]string, error) {
+	items := make([]string, 0, v.Len())
+	for i := range v.Len() {
+		ev, ok := templateValue(v.Index(i).Interface())
+		if !ok {
+			continue
+		}
+		s, err := templateScalar(ev)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, s)
+	}
+	return items, nil
+}
+
+func templateMap(v reflect.Value) ([][2]string, error) {
+	if v.Type().Key().Kind() != reflect.String {
+		return nil, NewError("map keys must be strings, got %s", v.Type().Key())
+	}
+	keys := v.MapKeys()
+	slices.SortFunc(keys, func(a, b reflect.Value) int {
+		return strings.Compare(a.String(), b.String())
+	})
+	pairs := make([][2]string, 0, len(keys))
+	for _, k := range keys {
+		ev, ok := templateValue(v.MapIndex(k).Interface())
+		if !ok {
+			continue
+		}
+		s, err := templateScalar(ev)
+		if err != nil {
+			return nil, err
+		}
+		pairs = append(pairs, [2]string{k.String(), s})
+	}
+	return pairs, nil
+}
+
+var stringerType = reflect.TypeFor[fmt.Stringer]()
+
+const upperHex = "0123456789ABCDEF"
+
+func isAlphaNum(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
+
+func isUnreserved(c byte) bool {
+	return isAlphaNum(c) || c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+func isReserved(c byte) bool {
+	return strings.IndexByte(":/?#[]@!$&'()*+,;=", c) >= 0
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+// encodeTemplateValue percent-encodes s, leaving unreserved characters intact.
+// When allowReserved is set, reserved characters and existing pct-encoded
+// triplets are also passed through.
+func encodeTemplateValue(s string, allowReserved bool) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case isUnreserved(c):
+			sb.WriteByte(c)
+		case allowReserved && isReserved(c):
+			sb.WriteByte(c)
+		case allowReserved && c == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]):
+			sb.WriteString(s[i : i+3])
+			i += 2
+		default:
+			sb.WriteByte('%')
+			sb.WriteByte(upperHex[c>>4])
+			sb.WriteByte(upperHex[c&15])
+		}
+	}
+	return sb.String()
+}
+
+// encodeTemplateLiteral encodes the literal parts of a template, which may
+// contain reserved characters but not spaces or other disallowed bytes.
+func encodeTemplateLiteral(s string) string {
+	return encodeTemplateValue(s, true)
+}
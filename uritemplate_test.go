@@ -0,0 +1,144 @@
+package choco
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// Examples from RFC 6570 section 3.2.
+var rfc6570Vars = map[string]any{
+	"count":      []string{"one", "two", "three"},
+	"dom":        []string{"example", "com"},
+	"dub":        "me/too",
+	"hello":      "Hello World!",
+	"half":       "50%",
+	"var":        "value",
+	"who":        "fred",
+	"base":       "http://example.com/home/",
+	"path":       "/foo/bar",
+	"list":       []string{"red", "green", "blue"},
+	"keys":       map[string]string{"comma": ",", "dot": ".", "semi": ";"},
+	"v":          "6",
+	"x":          "1024",
+	"y":          "768",
+	"empty":      "",
+	"empty_keys": map[string]string{},
+	"undef":      nil,
+}
+
+func TestExpandURITemplate(t *testing.T) {
+	tests := []struct {
+		tmpl string
+		want string
+	}{
+		// Level 1
+		{"{var}", "value"},
+		{"{hello}", "Hello%20World%21"},
+		{"{half}", "50%25"},
+		{"O{empty}X", "OX"},
+		{"O{undef}X", "OX"},
+		{"{x,y}", "1024,768"},
+		{"{x,hello,y}", "1024,Hello%20World%21,768"},
+		{"?{x,empty}", "?1024,"},
+		{"{var:3}", "val"},
+		{"{var:30}", "value"},
+		{"{list}", "red,green,blue"},
+		{"{list*}", "red,green,blue"},
+		{"{keys}", "comma,%2C,dot,.,semi,%3B"},
+		{"{keys*}", "comma=%2C,dot=.,semi=%3B"},
+		// Level 2
+		{"{+var}", "value"},
+		{"{+hello}", "Hello%20World!"},
+		{"{+half}", "50%25"},
+		{"{base}index", "http%3A%2F%2Fexample.com%2Fhome%2Findex"},
+		{"{+base}index", "http://example.com/home/index"},
+		{"{+path}/here", "/foo/bar/here"},
+		{"here?ref={+path}", "here?ref=/foo/bar"},
+		{"up{+path}{var}/here", "up/foo/barvalue/here"},
+		{"{+path:6}/here", "/foo/b/here"},
+		{"{+keys*}", "comma=,,dot=.,semi=;"},
+		{"{#var}", "#value"},
+		{"{#hello}", "#Hello%20World!"},
+		{"{#path:6}/here", "#/foo/b/here"},
+		{"{#list*}", "#red,green,blue"},
+		{"{#keys}", "#comma,,,dot,.,semi,;"},
+		// Level 3 and 4
+		{"X{.var}", "X.value"},
+		{"X{.x,y}", "X.1024.768"},
+		{"{.who,who}", ".fred.fred"},
+		{"www{.dom*}", "www.example.com"},
+		{"X{.list*}", "X.red.green.blue"},
+		{"X{.keys*}", "X.comma=%2C.dot=..semi=%3B"},
+		{"X{.empty_keys}", "X"},
+		{"{/who}", "/fred"},
+		{"{/var,x}/here", "/value/1024/here"},
+		{"{/var:1,var}", "/v/value"},
+		{"{/list*,path:4}", "/red/green/blue/%2Ffoo"},
+		{"{/keys*}", "/comma=%2C/dot=./semi=%3B"},
+		{"{;x,y}", ";x=1024;y=768"},
+		{"{;x,y,empty}", ";x=1024;y=768;empty"},
+		{"{;hello:5}", ";hello=Hello"},
+		{"{;list*}", ";list=red;list=green;list=blue"},
+		{"{;keys*}", ";comma=%2C;dot=.;semi=%3B"},
+		{"{?x,y}", "?x=1024&y=768"},
+		{"{?x,y,empty}", "?x=1024&y=768&empty="},
+		{"{?var:3}", "?var=val"},
+		{"{?list}", "?list=red,green,blue"},
+		{"{?list*}", "?list=red&list=green&list=blue"},
+		{"{?keys}", "?keys=comma,%2C,dot,.,semi,%3B"},
+		{"{?keys*}", "?comma=%2C&dot=.&semi=%3B"},
+		{"?fixed=yes{&x}", "?fixed=yes&x=1024"},
+		{"{&var:3}", "&var=val"},
+		{"{&list*}", "&list=red&list=green&list=blue"},
+		// Optional query variables
+		{"/search{?q,page}", "/search"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tmpl, func(t *testing.T) {
+			got, err := ExpandURITemplate(tt.tmpl, rfc6570Vars)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("ExpandURITemplate(%q) = %q, want %q", tt.tmpl, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandURITemplateErrors(t *testing.T) {
+	tests := []struct {
+		tmpl    string
+		wantMsg string
+	}{
+		{"/users/{id}", `"id"`},
+		{"/users/{id", "unterminated"},
+		{"/users/id}", "unmatched"},
+		{"{list:3}", "prefix"},
+		{"{=var}", "reserved"},
+	}
+	for _, tt := range tests {
+		_, err := ExpandURITemplate(tt.tmpl, rfc6570Vars)
+		if err == nil {
+			t.Errorf("ExpandURITemplate(%q): expected error", tt.tmpl)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.wantMsg) {
+			t.Errorf("ExpandURITemplate(%q) error = %q, want it to contain %q", tt.tmpl, err, tt.wantMsg)
+		}
+	}
+}
+
+func TestNewRequestTemplate(t *testing.T) {
+	req, err := NewRequestTemplate(context.Background(), "GET", "http://www.example.com/users/{id}/repos{?page,per_page}", map[string]any{
+		"id":   "octo cat",
+		"page": 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := req.Raw().URL.String(), "http://www.example.com/users/octo%20cat/repos?page=2"; got != want {
+		t.Errorf("URL = %q, want %q", got, want)
+	}
+}